@@ -0,0 +1,52 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+)
+
+var inPlace bool
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manages the config file",
+	Long: `Manages the config file. Config files encrypted with age in the sops format are
+decrypted transparently by every command.`,
+}
+
+// writeConfigFile writes data back to the file with --in-place, or to stdout.
+func writeConfigFile(filename string, data []byte) error {
+	if !inPlace {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, info.Mode())
+}
+
+// configFileArg returns the file passed as argument, or the --config file if only one
+// was given.
+func configFileArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	if len(cfgFiles) != 1 {
+		log.Fatal("Pass the file as argument when more than one --config is given")
+	}
+	return cfgFiles[0]
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+
+	configCmd.PersistentFlags().BoolVarP(&inPlace, "in-place", "i", false, "write the result back to the file instead of stdout")
+}
@@ -0,0 +1,50 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/thschue/platformer/pkg/sops"
+	"log"
+	"os"
+)
+
+// decryptCmd represents the decrypt command
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt [file]",
+	Short: "Decrypts a config file encrypted with \"config encrypt\" or sops",
+	Long: `Decrypts a config file with the age identities from $SOPS_AGE_KEY,
+$SOPS_AGE_KEY_FILE or the sops key file in the user config directory.`,
+	Args: cobra.MaximumNArgs(1),
+	Annotations: map[string]string{
+		skipConfigAnnotation: "true",
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := configFileArg(args)
+
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		identities, err := sops.Identities()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		decrypted, err := sops.Decrypt(data, identities)
+		if err != nil {
+			log.Fatalf("Error decrypting %s: %v", filename, err)
+		}
+
+		err = writeConfigFile(filename, decrypted)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(decryptCmd)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/thschue/platformer/pkg/graph"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	destroyYes   bool
+	destroyForce bool
+)
+
+// destroyCmd represents the destroy command
+var destroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Deletes everything declared in the config",
+	Long: `Deletes the declared replication rules, robot accounts with their ArgoCD secrets,
+registries and projects in Harbor, and the deploy keys, repositories and organizations
+in Gitea, in reverse dependency order. Harbor configuration values are left as they are.
+
+Asks for confirmation unless --yes is given. Projects which still contain artifacts are
+refused unless --force is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := waitForDependencies(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = destroy(cmd)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func destroy(cmd *cobra.Command) (err error) {
+	ctx := cmd.Context()
+	if !dryRun {
+		locked, release, lockErr := lockState(ctx, cfg)
+		if lockErr != nil {
+			return lockErr
+		}
+		defer func() {
+			err = errors.Join(err, release())
+		}()
+		ctx = locked
+	}
+
+	changes, err := cfg.PlanDestroy(destroyForce)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("Nothing to destroy")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME")
+	for _, change := range changes {
+		fmt.Fprintf(w, "%s\t%s\n", change.Kind, change.Name)
+	}
+	w.Flush()
+
+	if !destroyYes && !dryRun {
+		fmt.Printf("Delete these %d resources? Only \"yes\" will be accepted: ", len(changes))
+		answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			return errors.New("destroy cancelled")
+		}
+	}
+
+	deleted, err := cfg.Destroy(ctx, destroyForce)
+
+	var results []graph.Result
+	for _, change := range deleted {
+		results = append(results, graph.Result{Node: graph.Node{Kind: change.Kind, Name: change.Name}, Action: change.Action})
+	}
+	printSummary(os.Stdout, results)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(destroyCmd)
+
+	addWaitFlags(destroyCmd)
+	destroyCmd.Flags().BoolVarP(&destroyYes, "yes", "y", false, "do not ask for confirmation")
+	destroyCmd.Flags().BoolVar(&destroyForce, "force", false, "also delete projects which still contain artifacts")
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/thschue/platformer/pkg/config"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/sops"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	ageRecipients  []string
+	encryptedRegex string
+)
+
+// encryptCmd represents the encrypt command
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt [file]",
+	Short: "Encrypts the credentials in a config file",
+	Long: `Encrypts the values of all keys matching --encrypted-regex for the given age
+recipients, in the format of the sops CLI. All other values stay readable, so the
+file can be reviewed and diffed in Git.`,
+	Args: cobra.MaximumNArgs(1),
+	Annotations: map[string]string{
+		skipConfigAnnotation: "true",
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := configFileArg(args)
+
+		recipients := ageRecipients
+		if len(recipients) == 0 && os.Getenv("SOPS_AGE_RECIPIENTS") != "" {
+			recipients = strings.Split(os.Getenv("SOPS_AGE_RECIPIENTS"), ",")
+		}
+
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		encrypted, err := sops.Encrypt(data, recipients, encryptedRegex)
+		if err != nil {
+			log.Fatalf("Error encrypting %s: %v", filename, err)
+		}
+
+		err = writeConfigFile(filename, encrypted)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(encryptCmd)
+
+	encryptCmd.Flags().StringSliceVar(&ageRecipients, "age", nil, "age recipients to encrypt for (default $SOPS_AGE_RECIPIENTS)")
+	encryptCmd.Flags().StringVar(&encryptedRegex, "encrypted-regex", sops.EncryptedRegex(helpers.SecretKeys(config.Config{})), "encrypt the values below keys matching this regex")
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bytes"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+)
+
+var exportOut string
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Generates a config file from the existing Harbor and Gitea instances",
+	Long: `Reads the configuration, projects, registries, replication rules and robot accounts
+of Harbor and the organizations and repositories of Gitea, and writes them as a config
+file. Harbor and Gitea are reached with the connection settings of the loaded config.
+
+Registry secrets cannot be read from Harbor and have to be filled in. A run of the
+exported config is a no-op: resources which were not created by platformer are
+adopted by recording them in the state, and only get the ownership marker with their
+next update.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		readState(cmd.Context(), cfg)
+		err := cfg.Export()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		err = enc.Encode(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if exportOut == "" {
+			os.Stdout.Write(out.Bytes())
+			return
+		}
+
+		err = os.WriteFile(exportOut, out.Bytes(), 0600)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVarP(&exportOut, "out", "o", "", "write the config to this file instead of stdout")
+}
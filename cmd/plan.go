@@ -0,0 +1,56 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+)
+
+var (
+	planOut  string
+	planJson bool
+)
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Shows the changes run would apply to the platform",
+	Long: `Reads the live state of Harbor and Gitea, compares it with the configuration
+and shows which resources would be created, updated or left unchanged.
+The plan can be saved with --out and applied later with "run --plan".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		readState(cmd.Context(), cfg)
+		p, err := cfg.Plan(prune)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if planJson {
+			err = p.WriteJSON(os.Stdout)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			p.Render(os.Stdout)
+		}
+
+		if planOut != "" {
+			err = p.Save(planOut)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Plan saved to %s\n", planOut)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	planCmd.Flags().StringVarP(&planOut, "out", "o", "", "write the plan to this file")
+	planCmd.Flags().BoolVar(&prune, "prune", false, "plan the deletion of resources created by platformer which are no longer declared")
+	planCmd.Flags().BoolVar(&planJson, "json", false, "print the plan as JSON")
+}
@@ -4,15 +4,19 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/thschue/platformer/pkg/config"
 	"log"
 	"os"
+	"strings"
 )
 
 var (
-	cfg     *config.Config
-	cfgFile string
+	cfg      *config.Config
+	cfgFiles []string
+	dryRun   bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -28,8 +32,18 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if cmd.Annotations[skipConfigAnnotation] == "true" {
+			return
+		}
+		initConfig()
+	},
 }
 
+// Commands annotated with skipConfigAnnotation load the config file themselves, or
+// do not need it at all.
+const skipConfigAnnotation = "platformer/skip-config"
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -41,21 +55,51 @@ func Execute() {
 
 func initConfig() {
 	var err error
-	cfg, err = config.New(cfgFile)
+	cfg, err = loadConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
+}
+
+func loadConfig() (*config.Config, error) {
+	c, err := checkConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.ResolveSecrets(context.TODO())
+	if err != nil {
+		return nil, err
+	}
 
+	c.Harbor.DryRun = dryRun
+	c.Gitea.DryRun = dryRun
+	return c, nil
+}
+
+// checkConfig loads and validates the config files. Secret references are checked but
+// not resolved, so the files, variables and cluster they point to are not needed.
+func checkConfig() (*config.Config, error) {
+	c, err := config.Load(cfgFiles...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s:\n%w", strings.Join(cfgFiles, ", "), err)
+	}
+	return c, nil
 }
 
 func init() {
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", ".platformer.yaml", "config file (default is $HOME/.platformer.yaml)")
+	rootCmd.PersistentFlags().StringSliceVar(&cfgFiles, "config", []string{".platformer.yaml"}, "config files or directories, merged in the given order")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "read live state but only log the writes which would be made")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	cobra.OnInitialize(initConfig)
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/thschue/platformer/pkg/graph"
+	"github.com/thschue/platformer/pkg/harbor"
+	"github.com/thschue/platformer/pkg/plan"
+	"log"
+	"os"
+	"slices"
+)
+
+// rotateCmd represents the rotate command
+var rotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Rotates credentials",
+}
+
+// rotateRobotsCmd represents the rotate robots command
+var rotateRobotsCmd = &cobra.Command{
+	Use:   "robots [name...]",
+	Short: "Rotates the secrets of the declared robot accounts",
+	Long: `Gives the declared robot accounts, or only the named ones, a new secret through
+Harbor's refresh endpoint, extends their expiry by the declared duration and updates
+their ArgoCD repository secrets.
+
+Runs rotate a secret on their own once it is within rotateBefore days of its expiry.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := waitForDependencies(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = rotateRobots(cmd, args)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func rotateRobots(cmd *cobra.Command, names []string) (err error) {
+	for _, name := range names {
+		if !slices.ContainsFunc(cfg.Harbor.RobotAccounts, func(a harbor.RobotAccount) bool { return a.Name == name }) {
+			return fmt.Errorf("robot account %s is not declared", name)
+		}
+	}
+
+	ctx := cmd.Context()
+	if !dryRun {
+		locked, release, lockErr := lockState(ctx, cfg)
+		if lockErr != nil {
+			return lockErr
+		}
+		defer func() {
+			err = errors.Join(err, release())
+		}()
+		ctx = locked
+	}
+
+	var results []graph.Result
+	var errs []error
+	for _, account := range cfg.Harbor.RobotAccounts {
+		if len(names) > 0 && !slices.Contains(names, account.Name) {
+			continue
+		}
+
+		rotateErr := context.Cause(ctx)
+		if rotateErr == nil {
+			rotateErr = cfg.Harbor.RotateRobotAccount(account)
+		}
+		results = append(results, graph.Result{
+			Node:   graph.Node{Kind: harbor.KindRobotAccount, Name: account.Name},
+			Action: plan.ActionUpdate,
+			Err:    rotateErr,
+		})
+		errs = append(errs, rotateErr)
+	}
+
+	printSummary(os.Stdout, results)
+	return errors.Join(errs...)
+}
+
+func init() {
+	rootCmd.AddCommand(rotateCmd)
+	rotateCmd.AddCommand(rotateRobotsCmd)
+
+	addWaitFlags(rotateRobotsCmd)
+}
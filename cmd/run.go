@@ -4,98 +4,288 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/thschue/platformer/pkg/config"
+	"github.com/thschue/platformer/pkg/graph"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
+	"io"
 	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
-var counters = map[string]int{}
+var (
+	planFile        string
+	prune           bool
+	parallelism     int
+	continueOnError bool
+	backoff         helpers.Backoff
+)
 
 // runCmd represents the run command
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Configures the deployment of the platform",
-	Long:  `Configures the deployment of the platform`,
+	Long: `Configures the deployment of the platform.
+Prints a summary of every resource and exits with a non-zero code if any of them failed.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		_, err := cfg.Harbor.IsAvailable()
+		err := waitForDependencies(cmd.Context())
 		if err != nil {
-			counters["harbor"] = counters["harbor"] + 1
-			fmt.Println("Harbor is not available, retrying")
-			if counters["harbor"] > 10 {
-				fmt.Println("Error connecting to harbor")
-				log.Fatal("harbor is not available after 10 retries: %w", err)
-			}
+			log.Fatal(err)
 		}
 
-		_, err = cfg.Gitea.IsAvailable()
-		if err != nil {
-			counters["gitea"] = counters["gitea"] + 1
-			fmt.Println("Gitea is not available, retrying")
-			if counters["gitea"] > 10 {
-				fmt.Println("Error connecting to gitea")
-				log.Fatal("gitea is not available after 10 retries: %w", err)
+		var saved *plan.Plan
+		if planFile != "" {
+			saved, err = plan.Load(planFile)
+			if err != nil {
+				log.Fatal(err)
 			}
-		}
 
-		for k, v := range cfg.Harbor.Configuration {
-			data := map[string]interface{}{
-				k: v,
+			readState(cmd.Context(), cfg)
+			current, err := cfg.Plan(prune)
+			if err != nil {
+				log.Fatal(err)
 			}
-			err := cfg.Harbor.CreateConfiguration(data)
+
+			err = saved.Verify(current)
 			if err != nil {
-				log.Println("Error creating configuration: %w", err)
+				log.Fatalf("Refusing to apply %s: %v", planFile, err)
 			}
 		}
 
-		fmt.Println(cfg)
+		err = reconcile(cmd.Context(), cfg, saved)
+		if err != nil {
+			os.Exit(1)
+		}
+	},
+}
 
-		for _, project := range cfg.Harbor.Projects {
-			err := cfg.Harbor.CreateProject(project)
-			if err != nil {
-				log.Println("Error creating project: %w", err)
-			}
+// waitForDependencies blocks until Harbor and Gitea report healthy, so that a run
+// started next to the Helm releases it configures does not fail early.
+func waitForDependencies(ctx context.Context) error {
+	err := helpers.WaitFor(ctx, "Harbor", backoff, cfg.Harbor.IsAvailable)
+	if err != nil {
+		return err
+	}
+
+	return helpers.WaitFor(ctx, "Gitea", backoff, cfg.Gitea.IsAvailable)
+}
+
+// lockState locks and reads the state, which c records the objects it manages in until
+// release is called. Release saves the state even after a failed run, so that the
+// objects created before the failure are not forgotten. The returned context is
+// cancelled when the lock is lost, which stops the run.
+func lockState(ctx context.Context, c *config.Config) (context.Context, func() error, error) {
+	backend, err := state.NewBackend(c.State)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	locked, err := backend.Lock(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := backend.Read(locked)
+	if err != nil {
+		backend.Unlock(context.Background())
+		return nil, nil, err
+	}
+	c.SetState(s)
+
+	release := func() error {
+		c.SetState(nil)
+		err := errors.Join(backend.Write(context.Background(), s), backend.Unlock(context.Background()))
+		if err != nil {
+			log.Println(err)
 		}
+		return err
+	}
+	return locked, release, nil
+}
 
-		for _, registry := range cfg.Harbor.Registries {
-			err := cfg.Harbor.CreateRegistry(registry)
-			if err != nil {
-				log.Println("Error creating registry: %w", err)
-			}
+// readState reads the state without locking it, so that a plan matches the objects
+// platformer manages by their recorded IDs. A state which cannot be read is ignored.
+func readState(ctx context.Context, c *config.Config) {
+	backend, err := state.NewBackend(c.State)
+	if err == nil {
+		var s *state.State
+		s, err = backend.Read(ctx)
+		if err == nil {
+			c.SetState(s)
+			return
 		}
+	}
+	log.Println(fmt.Sprintf("Ignoring state: %v", err))
+}
 
-		for _, rule := range cfg.Harbor.Replications {
-			err := cfg.Harbor.CreateReplicationRule(rule)
-			if err != nil {
-				log.Println("Error creating replication rule: %w", err)
-			}
+func addWaitFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&backoff.Timeout, "wait-timeout", 5*time.Minute, "how long to wait for Harbor and Gitea to become available")
+	cmd.Flags().DurationVar(&backoff.InitialInterval, "wait-interval", 2*time.Second, "initial interval between two availability checks")
+	cmd.Flags().DurationVar(&backoff.MaxInterval, "wait-max-interval", 30*time.Second, "maximum interval between two availability checks")
+}
+
+// reconcile applies the configuration. With a saved plan only the resources with
+// pending changes in that plan are applied. Independent resources are applied
+// concurrently. The first failure stops the run, unless --continue-on-error is set,
+// in which case a failed resource only skips the resources depending on it.
+func reconcile(ctx context.Context, c *config.Config, saved *plan.Plan) (err error) {
+	if !dryRun {
+		locked, release, lockErr := lockState(ctx, c)
+		if lockErr != nil {
+			log.Println(lockErr)
+			return lockErr
 		}
+		defer func() {
+			err = errors.Join(err, release())
+		}()
+		ctx = locked
+	} else {
+		readState(ctx, c)
+	}
 
-		for _, account := range cfg.Harbor.RobotAccounts {
-			err := cfg.Harbor.CreateRobotAccount(account)
-			if err != nil {
-				log.Println("Error creating robot account: %w", err)
-			}
+	var errs []error
+	failed := func(err error) {
+		log.Println(err)
+		errs = append(errs, err)
+	}
+
+	pending := func(kind string, name string) bool {
+		return saved == nil || saved.Includes(kind, name)
+	}
+
+	g := graph.New()
+	err = g.Add(c.Harbor.Resources(pending)...)
+	if err != nil {
+		return err
+	}
+	err = g.Add(c.Gitea.Resources(pending)...)
+	if err != nil {
+		return err
+	}
+
+	results, err := g.Run(ctx, parallelism, !continueOnError)
+	if err != nil {
+		return err
+	}
+	// Independent resources finish in any order, so they are listed by kind and name.
+	// Pruned resources follow in the order they were deleted.
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Node.ID() < results[j].Node.ID()
+	})
+	for _, result := range results {
+		if result.Err != nil {
+			failed(result.Err)
 		}
+	}
 
-		for _, org := range cfg.Gitea.Orgs {
-			err := cfg.Gitea.CreateOrganization(org)
-			if err != nil {
-				log.Println("Error creating organization: %w", err)
-			}
+	if prune && ctx.Err() == nil && (len(errs) == 0 || continueOnError) {
+		// A lost lock stops pruning before the next deletion.
+		prunable := func(kind string, name string) bool {
+			return ctx.Err() == nil && pending(kind, name)
+		}
+
+		pruners := []struct {
+			name  string
+			prune func(func(kind string, name string) bool) ([]plan.Change, error)
+		}{
+			{"harbor", c.Harbor.Prune},
+			{"gitea", c.Gitea.Prune},
 		}
 
-		for _, repo := range cfg.Gitea.Repositories {
-			err := cfg.Gitea.CreateRepository(repo.Organization, repo)
+		for _, p := range pruners {
+			deleted, err := p.prune(prunable)
+			for _, change := range deleted {
+				results = append(results, graph.Result{Node: graph.Node{Kind: change.Kind, Name: change.Name}, Action: change.Action})
+			}
 			if err != nil {
-				log.Println("Error creating repository: %w", err)
+				err = fmt.Errorf("error pruning %s: %w", p.name, err)
+				failed(err)
+				results = append(results, graph.Result{Node: graph.Node{Kind: p.name, Name: "prune"}, Err: err})
 			}
 		}
-	},
+	}
+
+	// A run which lost its lock must not report success, even if nothing failed.
+	if cause := context.Cause(ctx); cause != nil && len(errs) == 0 {
+		failed(cause)
+	}
+
+	printSummary(os.Stdout, results)
+
+	return errors.Join(errs...)
+}
+
+func resultStatus(result graph.Result) string {
+	switch {
+	case result.Skipped:
+		return "skipped"
+	case result.Err != nil:
+		return "failed"
+	}
+
+	switch result.Action {
+	case plan.ActionCreate:
+		return "created"
+	case plan.ActionUpdate:
+		return "updated"
+	case plan.ActionDelete:
+		return "deleted"
+	default:
+		return "unchanged"
+	}
+}
+
+// printSummary prints one line per resource in the given order, followed by the totals
+// of every status.
+func printSummary(out io.Writer, results []graph.Result) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tSTATUS\tERROR")
+	counts := map[string]int{}
+	for _, result := range results {
+		status := resultStatus(result)
+		counts[status]++
+
+		message := ""
+		if result.Err != nil {
+			message = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Node.Kind, result.Node.Name, status, message)
+	}
+	w.Flush()
+
+	var totals []string
+	for _, status := range []string{"created", "updated", "deleted", "unchanged", "failed", "skipped"} {
+		totals = append(totals, fmt.Sprintf("%d %s", counts[status], status))
+	}
+	summary := "Summary: " + strings.Join(totals, ", ")
+	if dryRun {
+		summary += " (dry-run)"
+	}
+	fmt.Fprintln(out, summary)
+}
+
+func addApplyFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&prune, "prune", false, "delete resources created by platformer which are no longer declared")
+	cmd.Flags().IntVar(&parallelism, "parallelism", 4, "number of resources applied concurrently")
+	cmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "keep applying independent resources after a failure instead of stopping")
 }
 
 func init() {
 	rootCmd.AddCommand(runCmd)
 
+	addWaitFlags(runCmd)
+	addApplyFlags(runCmd)
+	runCmd.Flags().StringVar(&planFile, "plan", "", "apply a plan saved with \"plan --out\" instead of the whole configuration")
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"github.com/thschue/platformer/pkg/graph"
+	"github.com/thschue/platformer/pkg/plan"
+	"strings"
+	"testing"
+)
+
+func TestPrintSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []graph.Result
+		lines   []string
+		summary string
+	}{
+		{
+			name: "keeps the order of the results",
+			results: []graph.Result{
+				{Node: graph.Node{Kind: "harbor/project", Name: "library"}, Action: plan.ActionDelete},
+				{Node: graph.Node{Kind: "harbor/registry", Name: "hub"}, Action: plan.ActionDelete},
+				{Node: graph.Node{Kind: "gitea/appset", Name: "org/app/dev"}, Action: plan.ActionDelete},
+			},
+			lines:   []string{"harbor/project", "harbor/registry", "gitea/appset"},
+			summary: "Summary: 0 created, 0 updated, 3 deleted, 0 unchanged, 0 failed, 0 skipped",
+		},
+		{
+			name: "failed and skipped",
+			results: []graph.Result{
+				{Node: graph.Node{Kind: "harbor/registry", Name: "hub"}, Err: errors.New("unauthorized")},
+				{Node: graph.Node{Kind: "harbor/replication", Name: "hub"}, Skipped: true},
+				{Node: graph.Node{Kind: "harbor/project", Name: "library"}, Action: plan.ActionNoOp},
+			},
+			lines:   []string{"harbor/registry", "harbor/replication", "harbor/project"},
+			summary: "Summary: 0 created, 0 updated, 0 deleted, 1 unchanged, 1 failed, 1 skipped",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			printSummary(&out, tt.results)
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != len(tt.lines)+2 {
+				t.Fatalf("expected %d lines, got:\n%s", len(tt.lines)+2, out.String())
+			}
+			for i, kind := range tt.lines {
+				if !strings.HasPrefix(lines[i+1], kind+" ") {
+					t.Errorf("expected line %d to be %s, got %q", i+1, kind, lines[i+1])
+				}
+			}
+			if lines[len(lines)-1] != tt.summary {
+				t.Errorf("expected %q, got %q", tt.summary, lines[len(lines)-1])
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/spf13/cobra"
+	"github.com/thschue/platformer/pkg/config"
+	"log"
+	"os"
+)
+
+var schemaOut string
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Prints the JSON Schema of the config file",
+	Long: `Prints the JSON Schema of the config file. Editors with YAML language support pick
+it up with a "# yaml-language-server: $schema=config.schema.json" comment.`,
+	Annotations: map[string]string{
+		skipConfigAnnotation: "true",
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := json.MarshalIndent(config.Schema(), "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		data = append(data, '\n')
+
+		if schemaOut == "" {
+			os.Stdout.Write(data)
+			return
+		}
+
+		err = os.WriteFile(schemaOut, data, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+
+	schemaCmd.Flags().StringVarP(&schemaOut, "out", "o", "", "write the schema to this file")
+}
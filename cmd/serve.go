@@ -0,0 +1,227 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/thschue/platformer/pkg/config"
+	"github.com/thschue/platformer/pkg/plan"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	serveInterval time.Duration
+	serveAddress  string
+)
+
+type serveStatus struct {
+	mu        sync.Mutex
+	interval  time.Duration
+	lastTick  time.Time
+	lastRun   time.Time
+	lastError error
+	drift     []plan.Change
+}
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Continuously reconciles the platform",
+	Long: `Reconciles the platform on a fixed interval and whenever the config file changes.
+Every reconciliation reports the resources which drifted from the configuration
+before correcting them. Liveness and readiness are exposed on /healthz and /readyz.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if serveInterval <= 0 {
+			return fmt.Errorf("--interval has to be positive, got %s", serveInterval)
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		status := &serveStatus{interval: serveInterval, lastTick: time.Now()}
+		server := &http.Server{Addr: serveAddress, Handler: status.handler()}
+		go func() {
+			err := server.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+		defer server.Shutdown(context.Background())
+
+		err := waitForDependencies(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		reload := make(chan struct{}, 1)
+		notify := func() {
+			select {
+			case reload <- struct{}{}:
+			default:
+			}
+		}
+
+		// Includes may change on reload, which changes the files to watch.
+		stopWatch := func() {}
+		defer func() { stopWatch() }()
+		watch := func(c *config.Config) error {
+			stopWatch()
+			var watchCtx context.Context
+			watchCtx, stopWatch = context.WithCancel(ctx)
+			return config.Watch(watchCtx, watchPaths(c), notify)
+		}
+
+		err = watch(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ticker := time.NewTicker(serveInterval)
+		defer ticker.Stop()
+
+		current := cfg
+		for {
+			status.tick()
+			drift, err := reconcileDrift(ctx, current)
+			status.finished(drift, err)
+
+		wait:
+			for {
+				select {
+				case <-ctx.Done():
+					log.Println("Shutting down")
+					return
+				case <-ticker.C:
+					break wait
+				case <-reload:
+					c, err := loadConfig()
+					if err != nil {
+						log.Printf("Error reloading config, keeping the previous one: %v\n", err)
+						continue
+					}
+					log.Println("Config changed, reconciling")
+					if !slices.Equal(c.Sources(), current.Sources()) {
+						err = watch(c)
+						if err != nil {
+							log.Printf("Error watching config: %v\n", err)
+						}
+					}
+					current = c
+					ticker.Reset(serveInterval)
+					break wait
+				}
+			}
+		}
+	},
+}
+
+// reconcileDrift reports every resource which differs from the configuration and
+// applies only those.
+func reconcileDrift(ctx context.Context, c *config.Config) ([]plan.Change, error) {
+	readState(ctx, c)
+	p, err := c.Plan(prune)
+	if err != nil {
+		log.Printf("Error detecting drift: %v\n", err)
+		return nil, err
+	}
+
+	drift := p.Pending()
+	if len(drift) == 0 {
+		log.Println("No drift detected")
+		return nil, nil
+	}
+
+	for _, change := range drift {
+		log.Printf("Drift detected: %s %s needs %s\n", change.Kind, change.Name, change.Action)
+		for _, diff := range change.Diff {
+			log.Printf("  %s: %v => %v\n", diff.Field, diff.Before, diff.After)
+		}
+	}
+
+	return drift, reconcile(ctx, c, p)
+}
+
+// watchPaths returns the config files and directories given on the command line
+// together with all files they include.
+func watchPaths(c *config.Config) []string {
+	return append(append([]string{}, cfgFiles...), c.Sources()...)
+}
+
+func (s *serveStatus) tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastTick = time.Now()
+}
+
+func (s *serveStatus) finished(drift []plan.Change, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastTick = time.Now()
+	s.lastRun = time.Now()
+	s.lastError = err
+	s.drift = drift
+}
+
+func (s *serveStatus) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	// Live as long as the reconcile loop keeps ticking.
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if time.Since(s.lastTick) > 3*s.interval {
+			http.Error(w, "reconcile loop is stuck", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	// Ready once the last reconciliation succeeded.
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.lastRun.IsZero() {
+			http.Error(w, "not reconciled yet", http.StatusServiceUnavailable)
+			return
+		}
+		if s.lastError != nil {
+			http.Error(w, s.lastError.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/drift", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lastRun": s.lastRun,
+			"drift":   s.drift,
+		})
+	})
+
+	return mux
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().DurationVar(&serveInterval, "interval", 5*time.Minute, "time between two reconciliations")
+	serveCmd.Flags().StringVar(&serveAddress, "listen", ":8080", "address of the health endpoints")
+	addWaitFlags(serveCmd)
+	addApplyFlags(serveCmd)
+}
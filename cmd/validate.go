@@ -0,0 +1,36 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks the config file without talking to Harbor or Gitea",
+	Long: `Decodes the config file strictly, rejecting unknown keys, and checks that every
+reference points to a declared resource: replication source registries, robot
+account projects and repository organizations. Cron expressions are parsed as well.
+Secret references are checked to name exactly one source but are not resolved.`,
+	Annotations: map[string]string{
+		skipConfigAnnotation: "true",
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := checkConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s is valid\n", strings.Join(cfgFiles, ", "))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
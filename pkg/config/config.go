@@ -1,18 +1,52 @@
 package config
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/state"
+	"reflect"
 	"strings"
 )
 
-func New(filename string) (*Config, error) {
+// New loads the config from files and directories and resolves its secrets.
+func New(paths ...string) (*Config, error) {
+	config, err := Load(paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.ResolveSecrets(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Load loads the config from files and directories without resolving its secrets.
+// Later paths and the files they include are merged over earlier ones, then string
+// values are rendered as templates with the merged vars.
+func Load(paths ...string) (*Config, error) {
+	l := &loader{visiting: map[string]bool{}}
+	values, err := l.load(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err = render(values)
+	if err != nil {
+		return nil, err
+	}
+
 	v := viper.New()
-	v.SetConfigFile(filename)
-	v.SetConfigType("yaml")
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
-	if err := v.ReadInConfig(); err != nil {
+	if err := v.MergeConfigMap(values); err != nil {
 		return nil, fmt.Errorf("error reading config: %w", err)
 	}
 
@@ -29,9 +63,63 @@ func New(filename string) (*Config, error) {
 
 	var config *Config
 
-	if err := v.Unmarshal(&config); err != nil {
+	// Decode by the yaml tags and reject unknown keys, so that a misspelled key fails
+	// loudly instead of being ignored.
+	err = v.UnmarshalExact(&config, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "yaml"
+		dc.DecodeHook = mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+			stringToSecretHookFunc(),
+		)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("error unmarshalling config: %w", err)
 	}
 
+	config.sources = l.sources
+
 	return config, nil
 }
+
+// ResolveSecrets reads the values of the secret references from files, the environment
+// and the cluster.
+func (c *Config) ResolveSecrets(ctx context.Context) error {
+	err := helpers.ResolveSecrets(ctx, c)
+	if err != nil {
+		return fmt.Errorf("error resolving secrets: %w", err)
+	}
+	return nil
+}
+
+// stringToSecretHookFunc keeps plain strings working wherever a secret is expected.
+func stringToSecretHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to != reflect.TypeOf(helpers.Secret{}) {
+			return data, nil
+		}
+		return helpers.Secret{Value: data.(string)}, nil
+	}
+}
+
+// SetState lets Harbor and Gitea record the objects they manage in s.
+func (c *Config) SetState(s *state.State) {
+	c.Harbor.SetState(s)
+	c.Gitea.SetState(s)
+}
+
+// Sources returns all files the config was loaded from, including the included ones.
+func (c *Config) Sources() []string {
+	return c.sources
+}
+
+// Checksum identifies the desired state described by the configuration.
+func (c *Config) Checksum() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling config: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/thschue/platformer/pkg/plan"
+)
+
+// PlanDestroy lists everything Destroy would delete, in order.
+func (c *Config) PlanDestroy(force bool) ([]plan.Change, error) {
+	changes, err := c.Harbor.PlanDestroy(force)
+	if err != nil {
+		return nil, fmt.Errorf("error planning harbor: %w", err)
+	}
+
+	giteaChanges, err := c.Gitea.PlanDestroy()
+	if err != nil {
+		return nil, fmt.Errorf("error planning gitea: %w", err)
+	}
+	return append(changes, giteaChanges...), nil
+}
+
+// Destroy deletes the declared Harbor and Gitea resources and stops at the first
+// failure or once ctx is done. It returns the deletions applied so far.
+func (c *Config) Destroy(ctx context.Context, force bool) ([]plan.Change, error) {
+	deleted, err := c.Harbor.Destroy(ctx, force)
+	if err == nil {
+		err = context.Cause(ctx)
+	}
+	if err != nil {
+		return deleted, fmt.Errorf("error destroying harbor: %w", err)
+	}
+
+	giteaDeleted, err := c.Gitea.Destroy(ctx)
+	deleted = append(deleted, giteaDeleted...)
+	if err == nil {
+		err = context.Cause(ctx)
+	}
+	if err != nil {
+		return deleted, fmt.Errorf("error destroying gitea: %w", err)
+	}
+	return deleted, nil
+}
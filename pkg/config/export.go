@@ -0,0 +1,18 @@
+package config
+
+import "fmt"
+
+// Export replaces the declared resources with the live ones of Harbor and Gitea, keeping
+// the connection settings, so that the result can be written as a new config file.
+func (c *Config) Export() error {
+	err := c.Harbor.Export()
+	if err != nil {
+		return fmt.Errorf("error exporting harbor: %w", err)
+	}
+
+	err = c.Gitea.Export()
+	if err != nil {
+		return fmt.Errorf("error exporting gitea: %w", err)
+	}
+	return nil
+}
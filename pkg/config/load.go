@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"github.com/thschue/platformer/pkg/sops"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+)
+
+const includeKey = "include"
+
+// loader reads config files, follows their includes and merges everything in order.
+type loader struct {
+	sources  []string
+	visiting map[string]bool
+}
+
+func (l *loader) load(paths []string) (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+	for _, path := range paths {
+		values, err := l.loadPath(path)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeMaps("", merged, values)
+	}
+	return merged, nil
+}
+
+// loadPath loads a file, or all yaml files of a directory in lexical order.
+func (l *loader) loadPath(path string) (map[string]interface{}, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+	if !info.IsDir() {
+		return l.loadFile(path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return l.load(files)
+}
+
+// loadFile decodes a file, decrypting it if needed. The files it includes are merged
+// first, so that the including file overrides them.
+func (l *loader) loadFile(filename string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	if l.visiting[abs] {
+		return nil, fmt.Errorf("include cycle through %s", filename)
+	}
+	l.visiting[abs] = true
+	defer delete(l.visiting, abs)
+	l.sources = append(l.sources, filename)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+
+	if sops.IsEncrypted(data) {
+		identities, err := sops.Identities()
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting %s: %w", filename, err)
+		}
+		data, err = sops.Decrypt(data, identities)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting %s: %w", filename, err)
+		}
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", filename, err)
+	}
+
+	includes, err := includePaths(filename, values[includeKey])
+	if err != nil {
+		return nil, err
+	}
+	delete(values, includeKey)
+
+	base, err := l.load(includes)
+	if err != nil {
+		return nil, err
+	}
+	return mergeMaps("", base, values), nil
+}
+
+// includePaths resolves the include directive, a path or a list of paths relative to
+// the including file.
+func includePaths(filename string, include interface{}) ([]string, error) {
+	var paths []string
+	switch include := include.(type) {
+	case nil:
+	case string:
+		paths = append(paths, include)
+	case []interface{}:
+		for _, path := range include {
+			s, ok := path.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: include has to be a list of paths", filename)
+			}
+			paths = append(paths, s)
+		}
+	default:
+		return nil, fmt.Errorf("%s: include has to be a path or a list of paths", filename)
+	}
+
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(filepath.Dir(filename), path)
+		}
+	}
+	return paths, nil
+}
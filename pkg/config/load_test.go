@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files below a temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadIncludes(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		url   string
+		err   string
+	}{
+		{
+			name: "include is overridden",
+			files: map[string]string{
+				"main.yaml": "include: base.yaml\nharbor: {url: https://main}\n",
+				"base.yaml": "harbor: {url: https://base, credentials: {username: admin}}\n",
+			},
+			url: "https://main",
+		},
+		{
+			name: "includes relative to the including file",
+			files: map[string]string{
+				"main.yaml":        "include: [common/base.yaml]\n",
+				"common/base.yaml": "include: url.yaml\n",
+				"common/url.yaml":  "harbor: {url: https://common}\n",
+			},
+			url: "https://common",
+		},
+		{
+			name: "later includes win",
+			files: map[string]string{
+				"main.yaml": "include: [a.yaml, b.yaml]\n",
+				"a.yaml":    "harbor: {url: https://a}\n",
+				"b.yaml":    "harbor: {url: https://b}\n",
+			},
+			url: "https://b",
+		},
+		{
+			name: "shared include",
+			files: map[string]string{
+				"main.yaml":   "include: [a.yaml, b.yaml]\n",
+				"a.yaml":      "include: common.yaml\n",
+				"b.yaml":      "include: common.yaml\n",
+				"common.yaml": "harbor: {url: https://common}\n",
+			},
+			url: "https://common",
+		},
+		{
+			name: "self include",
+			files: map[string]string{
+				"main.yaml": "include: main.yaml\n",
+			},
+			err: "include cycle through",
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"main.yaml": "include: a.yaml\n",
+				"a.yaml":    "include: b.yaml\n",
+				"b.yaml":    "include: a.yaml\n",
+			},
+			err: "include cycle through",
+		},
+		{
+			name: "missing include",
+			files: map[string]string{
+				"main.yaml": "include: missing.yaml\n",
+			},
+			err: "error reading config",
+		},
+		{
+			name: "invalid include",
+			files: map[string]string{
+				"main.yaml": "include: {path: a.yaml}\n",
+			},
+			err: "include has to be a path or a list of paths",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+
+			c, err := Load(filepath.Join(dir, "main.yaml"))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Harbor.Url != tt.url {
+				t.Errorf("expected url %s, got %s", tt.url, c.Harbor.Url)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// listKeys names the fields identifying the items of resource lists. An overlay
+// merges into the item with the same key instead of replacing the whole list, and
+// items with new keys are appended. All other lists are replaced. Fields ending with
+// "?" may be missing, e.g. members have either a user or a group, and retention rules
+// are identified by their selectors, which default to all tags and repositories.
+var listKeys = map[string][]string{
+	"harbor.projects":                 {"name"},
+	"harbor.projects.members":         {"user?", "group?"},
+	"harbor.projects.retention.rules": {"includeTags?", "excludeTags?", "includeRepositories?", "excludeRepositories?"},
+	"harbor.projects.immutableRules":  {"includeTags?", "excludeTags?", "includeRepositories?", "excludeRepositories?"},
+	"harbor.projects.webhooks":        {"name"},
+	"harbor.registries":               {"name"},
+	"harbor.replications":             {"repository"},
+	"harbor.robotAccounts":            {"name"},
+	"gitea.orgs":                      {"name"},
+	"gitea.repositories":              {"organization", "name"},
+	"gitea.repositories.stages":       {"name"},
+}
+
+func mergeMaps(path string, base map[string]interface{}, overlay map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = mergeValues(joinPath(path, k), merged[k], v)
+	}
+	return merged
+}
+
+func mergeValues(path string, base interface{}, overlay interface{}) interface{} {
+	switch overlay := overlay.(type) {
+	case map[string]interface{}:
+		if base, ok := base.(map[string]interface{}); ok {
+			return mergeMaps(path, base, overlay)
+		}
+	case []interface{}:
+		keys, keyed := listKeys[path]
+		if base, ok := base.([]interface{}); ok && keyed {
+			return mergeLists(path, keys, base, overlay)
+		}
+	}
+	return overlay
+}
+
+func mergeLists(path string, keys []string, base []interface{}, overlay []interface{}) []interface{} {
+	merged := append([]interface{}{}, base...)
+	index := map[string]int{}
+	for i, item := range merged {
+		if key, ok := itemKey(keys, item); ok {
+			index[key] = i
+		}
+	}
+
+	for _, item := range overlay {
+		key, ok := itemKey(keys, item)
+		if i, found := index[key]; ok && found {
+			merged[i] = mergeValues(path, merged[i], item)
+			continue
+		}
+		if ok {
+			index[key] = len(merged)
+		}
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+func itemKey(keys []string, item interface{}) (string, bool) {
+	values, ok := item.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	var parts []string
+	for _, key := range keys {
+		field, optional := strings.CutSuffix(key, "?")
+		value, ok := values[field]
+		switch {
+		case !ok && !optional:
+			return "", false
+		case !ok:
+			value = ""
+		}
+		parts = append(parts, fmt.Sprint(value))
+	}
+	return strings.Join(parts, "/"), true
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"testing"
+)
+
+func TestMergeMaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		merged  string
+	}{
+		{
+			name:    "scalars",
+			base:    "harbor: {url: https://a, credentials: {username: admin}}",
+			overlay: "harbor: {url: https://b}",
+			merged:  "harbor: {url: https://b, credentials: {username: admin}}",
+		},
+		{
+			name:    "projects by name",
+			base:    "harbor: {projects: [{name: library, public: true}, {name: team}]}",
+			overlay: "harbor: {projects: [{name: library, storageLimit: 10Gi}, {name: new}]}",
+			merged:  "harbor: {projects: [{name: library, public: true, storageLimit: 10Gi}, {name: team}, {name: new}]}",
+		},
+		{
+			name:    "repositories by organization and name",
+			base:    "gitea: {repositories: [{organization: a, name: app, private: true}, {organization: b, name: app}]}",
+			overlay: "gitea: {repositories: [{organization: b, name: app, private: true}]}",
+			merged:  "gitea: {repositories: [{organization: a, name: app, private: true}, {organization: b, name: app, private: true}]}",
+		},
+		{
+			name:    "stages of a repository",
+			base:    "gitea: {repositories: [{organization: a, name: app, stages: [{name: dev}, {name: prod, argoProject: a}]}]}",
+			overlay: "gitea: {repositories: [{organization: a, name: app, stages: [{name: prod, argoProject: b}]}]}",
+			merged:  "gitea: {repositories: [{organization: a, name: app, stages: [{name: dev}, {name: prod, argoProject: b}]}]}",
+		},
+		{
+			name:    "members by user or group",
+			base:    "harbor: {projects: [{name: library, members: [{user: alice, role: guest}, {group: alice, role: guest}]}]}",
+			overlay: "harbor: {projects: [{name: library, members: [{group: alice, role: developer}, {user: bob, role: guest}]}]}",
+			merged:  "harbor: {projects: [{name: library, members: [{user: alice, role: guest}, {group: alice, role: developer}, {user: bob, role: guest}]}]}",
+		},
+		{
+			name:    "retention rules by selectors",
+			base:    "harbor: {projects: [{name: library, retention: {schedule: daily, rules: [{keepMostRecent: 10}, {includeTags: 'v*', keepMostRecent: 5}]}}]}",
+			overlay: "harbor: {projects: [{name: library, retention: {rules: [{keepMostRecent: 20}]}}]}",
+			merged:  "harbor: {projects: [{name: library, retention: {schedule: daily, rules: [{keepMostRecent: 20}, {includeTags: 'v*', keepMostRecent: 5}]}}]}",
+		},
+		{
+			name:    "immutability rules by selectors",
+			base:    "harbor: {projects: [{name: library, immutableRules: [{includeTags: 'v*'}]}]}",
+			overlay: "harbor: {projects: [{name: library, immutableRules: [{includeTags: 'v*', disabled: true}, {excludeTags: latest}]}]}",
+			merged:  "harbor: {projects: [{name: library, immutableRules: [{includeTags: 'v*', disabled: true}, {excludeTags: latest}]}]}",
+		},
+		{
+			name:    "webhooks by name",
+			base:    "harbor: {projects: [{name: library, webhooks: [{name: ci, address: 'https://a', eventTypes: [PUSH_ARTIFACT]}]}]}",
+			overlay: "harbor: {projects: [{name: library, webhooks: [{name: ci, address: 'https://b'}]}]}",
+			merged:  "harbor: {projects: [{name: library, webhooks: [{name: ci, address: 'https://b', eventTypes: [PUSH_ARTIFACT]}]}]}",
+		},
+		{
+			name:    "unkeyed lists are replaced",
+			base:    "harbor: {projects: [{name: library, webhooks: [{name: ci, eventTypes: [PUSH_ARTIFACT, PULL_ARTIFACT]}]}]}",
+			overlay: "harbor: {projects: [{name: library, webhooks: [{name: ci, eventTypes: [DELETE_ARTIFACT]}]}]}",
+			merged:  "harbor: {projects: [{name: library, webhooks: [{name: ci, eventTypes: [DELETE_ARTIFACT]}]}]}",
+		},
+		{
+			name:    "items without key are appended",
+			base:    "harbor: {projects: [{name: library}]}",
+			overlay: "harbor: {projects: [{public: true}]}",
+			merged:  "harbor: {projects: [{name: library}, {public: true}]}",
+		},
+	}
+
+	decode := func(t *testing.T, s string) map[string]interface{} {
+		t.Helper()
+		values := map[string]interface{}{}
+		err := yaml.Unmarshal([]byte(s), &values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeMaps("", decode(t, tt.base), decode(t, tt.overlay))
+			if want := decode(t, tt.merged); !reflect.DeepEqual(merged, want) {
+				t.Errorf("expected\n%v\ngot\n%v", want, merged)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"github.com/thschue/platformer/pkg/plan"
+)
+
+// Plan computes the changes needed to bring Harbor and Gitea to the declared state.
+func (c *Config) Plan(prune bool) (*plan.Plan, error) {
+	checksum, err := c.Checksum()
+	if err != nil {
+		return nil, err
+	}
+	p := plan.New(checksum)
+
+	changes, err := c.Harbor.Plan(prune)
+	if err != nil {
+		return nil, fmt.Errorf("error planning harbor: %w", err)
+	}
+	p.Add(changes...)
+
+	changes, err = c.Gitea.Plan(prune)
+	if err != nil {
+		return nil, fmt.Errorf("error planning gitea: %w", err)
+	}
+	p.Add(changes...)
+
+	return p, nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"code.gitea.io/sdk/gitea"
+	"github.com/invopop/jsonschema"
+	"github.com/thschue/platformer/pkg/helpers"
+	"path"
+	"reflect"
+	"strings"
+)
+
+const schemaID = "https://github.com/thschue/platformer/config.schema.json"
+
+// Schema returns the JSON Schema of the config file, for validation and autocompletion
+// in editors.
+func Schema() *jsonschema.Schema {
+	r := &jsonschema.Reflector{
+		FieldNameTag:               "yaml",
+		RequiredFromJSONSchemaTags: true,
+		ExpandedStruct:             true,
+		// Both gitea and harbor declare a Config, so definitions are qualified by package.
+		Namer: func(t reflect.Type) string {
+			if t.PkgPath() == "" {
+				return t.Name()
+			}
+			pkg := path.Base(t.PkgPath())
+			return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+		},
+		Mapper: func(t reflect.Type) *jsonschema.Schema {
+			if t == reflect.TypeOf(helpers.Secret{}) {
+				return secretSchema()
+			}
+			if t == reflect.TypeOf(gitea.VisibleType("")) {
+				return &jsonschema.Schema{
+					Type: "string",
+					Enum: []interface{}{gitea.VisibleTypePublic, gitea.VisibleTypeLimited, gitea.VisibleTypePrivate},
+				}
+			}
+			return nil
+		},
+	}
+
+	schema := r.Reflect(&Config{})
+	schema.Properties.Set(includeKey, &jsonschema.Schema{
+		Description: "Config files or directories merged before this file",
+		OneOf: []*jsonschema.Schema{
+			{Type: "string"},
+			{Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+		},
+	})
+	schema.Properties.Set(varsKey, &jsonschema.Schema{
+		Description: "Variables which string values can reference as {{ .Vars.name }}",
+		Type:        "object",
+	})
+	schema.ID = schemaID
+	schema.Title = "platformer configuration"
+	return schema
+}
+
+// A secret is either a plain string or an object with value or valueFrom.
+func secretSchema() *jsonschema.Schema {
+	r := &jsonschema.Reflector{
+		FieldNameTag:               "yaml",
+		RequiredFromJSONSchemaTags: true,
+		DoNotReference:             true,
+		Anonymous:                  true,
+	}
+
+	ref := r.Reflect(&helpers.Secret{})
+	ref.Version = ""
+	return &jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{
+			{Type: "string"},
+			ref,
+		},
+	}
+}
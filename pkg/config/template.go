@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+const varsKey = "vars"
+
+// templateData is what config values can reference, e.g. {{ .Vars.domain }} or
+// {{ .Env.HOME }}.
+type templateData struct {
+	Vars map[string]interface{}
+	Env  map[string]string
+}
+
+var templateFuncs = template.FuncMap{
+	"env": os.Getenv,
+	"default": func(def interface{}, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return def
+		}
+		return value
+	},
+	"required": func(msg string, value interface{}) (interface{}, error) {
+		if value == nil || value == "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return value, nil
+	},
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old string, new string, s string) string { return strings.ReplaceAll(s, old, new) },
+	"join": func(sep string, values []interface{}) string {
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = fmt.Sprint(v)
+		}
+		return strings.Join(parts, sep)
+	},
+}
+
+// render removes the vars block from the merged config and renders every string value
+// as a Go template. Vars themselves can only use the environment and the functions.
+func render(values map[string]interface{}) (map[string]interface{}, error) {
+	data := templateData{Env: environ()}
+
+	if raw, ok := values[varsKey]; ok {
+		vars, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s has to be a map", varsKey)
+		}
+		rendered, err := renderValue(varsKey, vars, data)
+		if err != nil {
+			return nil, err
+		}
+		data.Vars = rendered.(map[string]interface{})
+	}
+
+	rest := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if k != varsKey {
+			rest[k] = v
+		}
+	}
+
+	rendered, err := renderValue("", rest, data)
+	if err != nil {
+		return nil, err
+	}
+	return rendered.(map[string]interface{}), nil
+}
+
+func renderValue(path string, value interface{}, data templateData) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			r, err := renderValue(joinPath(path, k), v, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[k] = r
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(value))
+		for i, v := range value {
+			r, err := renderValue(fmt.Sprintf("%s[%d]", path, i), v, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[i] = r
+		}
+		return rendered, nil
+	case string:
+		if !strings.Contains(value, "{{") {
+			return value, nil
+		}
+		return renderString(path, value, data)
+	}
+	return value, nil
+}
+
+func renderString(path string, value string, data templateData) (string, error) {
+	t, err := template.New(path).Option("missingkey=error").Funcs(templateFuncs).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template of %s: %w", path, err)
+	}
+
+	var out strings.Builder
+	err = t.Execute(&out, data)
+	if err != nil {
+		return "", fmt.Errorf("error rendering template of %s: %w", path, err)
+	}
+	return out.String(), nil
+}
+
+func environ() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	return env
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	t.Setenv("PLATFORMER_TEST_DOMAIN", "example.com")
+
+	tests := []struct {
+		name     string
+		config   string
+		rendered string
+		err      string
+	}{
+		{
+			name:     "vars",
+			config:   "vars: {domain: example.com}\nharbor: {url: 'https://harbor.{{ .Vars.domain }}'}",
+			rendered: "harbor: {url: https://harbor.example.com}",
+		},
+		{
+			name:     "vars from the environment",
+			config:   "vars: {domain: '{{ .Env.PLATFORMER_TEST_DOMAIN }}'}\ngitea: {url: 'https://git.{{ .Vars.domain | upper }}'}",
+			rendered: "gitea: {url: https://git.EXAMPLE.COM}",
+		},
+		{
+			name:     "functions",
+			config:   "harbor: {url: '{{ env \"PLATFORMER_TEST_MISSING\" | default \"https://localhost\" }}'}",
+			rendered: "harbor: {url: https://localhost}",
+		},
+		{
+			name:     "values without templates",
+			config:   "harbor: {projects: [{name: library, public: true, storageLimit: 10}]}",
+			rendered: "harbor: {projects: [{name: library, public: true, storageLimit: 10}]}",
+		},
+		{
+			name:   "missing var",
+			config: "vars: {}\nharbor: {url: '{{ .Vars.domain }}'}",
+			err:    "error rendering template of harbor.url",
+		},
+		{
+			name:   "location in a list",
+			config: "harbor: {projects: [{name: library}, {name: '{{ .Vars.team }}'}]}",
+			err:    "error rendering template of harbor.projects[1].name",
+		},
+		{
+			name:   "syntax error",
+			config: "gitea: {repositories: [{name: '{{ .Vars.name '}]}",
+			err:    "error parsing template of gitea.repositories[0].name",
+		},
+		{
+			name:   "required value",
+			config: "vars: {domain: ''}\nharbor: {url: '{{ required \"domain is required\" .Vars.domain }}'}",
+			err:    "domain is required",
+		},
+		{
+			name:   "vars referencing vars",
+			config: "vars: {domain: example.com, url: '{{ .Vars.domain }}'}",
+			err:    "error rendering template of vars.url",
+		},
+		{
+			name:   "vars is no map",
+			config: "vars: [domain]",
+			err:    "vars has to be a map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]interface{}{}
+			err := yaml.Unmarshal([]byte(tt.config), &values)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rendered, err := render(values)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]interface{}{}
+			err = yaml.Unmarshal([]byte(tt.rendered), &want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rendered, want) {
+				t.Errorf("expected\n%v\ngot\n%v", want, rendered)
+			}
+		})
+	}
+}
@@ -3,9 +3,13 @@ package config
 import (
 	"github.com/thschue/platformer/pkg/gitea"
 	"github.com/thschue/platformer/pkg/harbor"
+	"github.com/thschue/platformer/pkg/state"
 )
 
 type Config struct {
 	Gitea  gitea.Config  `yaml:"gitea"`
 	Harbor harbor.Config `yaml:"harbor"`
+	State  state.Config  `yaml:"state,omitempty"`
+
+	sources []string
 }
@@ -0,0 +1,12 @@
+package config
+
+import (
+	"errors"
+	"github.com/thschue/platformer/pkg/helpers"
+)
+
+// Validate checks the configuration for missing names, duplicates and dangling
+// references without talking to any API.
+func (c *Config) Validate() error {
+	return errors.Join(c.Harbor.Validate(), c.Gitea.Validate(), c.State.Validate(), helpers.CheckSecrets(c))
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Watch calls onChange whenever one of the config files is written or replaced, or a
+// yaml file is added to one of the config directories. Directories are watched instead
+// of the files themselves, so that atomic replacements like the symlink swap of a
+// mounted ConfigMap are noticed as well.
+func Watch(ctx context.Context, paths []string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating config watcher: %w", err)
+	}
+
+	// files maps every config file to the path its symlinks currently resolve to.
+	files := map[string]string{}
+	configDirs := map[string]bool{}
+	watched := map[string]bool{}
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			configDirs[path] = true
+			watched[path] = true
+			continue
+		}
+		files[path], _ = filepath.EvalSymlinks(path)
+		watched[filepath.Dir(path)] = true
+	}
+
+	for dir := range watched {
+		err = watcher.Add(dir)
+		if err != nil {
+			watcher.Close()
+			return fmt.Errorf("error watching config: %w", err)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				name := filepath.Clean(event.Name)
+				changed := false
+				if ext := filepath.Ext(name); configDirs[filepath.Dir(name)] && (ext == ".yaml" || ext == ".yml") {
+					changed = true
+				}
+				for filename, realPath := range files {
+					currentPath, _ := filepath.EvalSymlinks(filename)
+					written := name == filename && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+					replaced := currentPath != "" && currentPath != realPath
+					if written || replaced {
+						files[filename] = currentPath
+						changed = true
+					}
+				}
+
+				if changed {
+					onChange()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Error watching config: %v\n", err)
+			}
+		}
+	}()
+	return nil
+}
@@ -4,69 +4,62 @@ import (
 	"bytes"
 	"code.gitea.io/sdk/gitea"
 	"context"
-	"crypto/tls"
 	"encoding/base64"
 	"fmt"
 	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 	"log"
-	"net/http"
 	"text/template"
 )
 
-func (g *Config) createDeployKey(repository Repository) (*gitea.Response, error) {
-	// Example usage
+const argoNamespace = "argocd"
+
+func deployKeySecretName(repo Repository) string {
+	return repo.Name + "-deploy-key"
+}
+
+func (g *Config) createDeployKey(client *gitea.Client, repository Repository) error {
 	privateKey, publicKey, err := helpers.GenerateSSHKeyPair()
 	if err != nil {
-		return &gitea.Response{}, fmt.Errorf("failed to generate SSH key pair: %w", err)
+		return fmt.Errorf("failed to generate SSH key pair: %w", err)
 	}
 
-	client, err := gitea.NewClient(g.Url, gitea.SetBasicAuth(g.Credentials.Username, g.Credentials.Password), gitea.SetHTTPClient(&http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: g.TLSConfig.InsecureSkipVerify,
-			},
-		},
-	}))
-
 	deployKeyOption := gitea.CreateKeyOption{
-		Title:    "GitOps Deployment Key",
+		Title:    deployKeyTitle,
 		Key:      publicKey,
 		ReadOnly: true,
 	}
-	_, resp, err := client.CreateDeployKey(repository.Organization, repository.Name, deployKeyOption)
-	if err != nil {
-		return resp, fmt.Errorf("failed to create deploy key: %w", err)
+
+	if g.DryRun {
+		helpers.LogDryRun("CREATE", "deploy key "+repositoryName(repository), deployKeyOption)
+	} else {
+		key, _, err := client.CreateDeployKey(repository.Organization, repository.Name, deployKeyOption)
+		if err != nil {
+			return fmt.Errorf("failed to create deploy key: %w", err)
+		}
+		g.state.Set(state.Resource{Kind: KindDeployKey, Name: repositoryName(repository), ID: key.ID})
 	}
 
-	err = createKubernetesSecretForArgoCD(g.Namespace, repository.Name+"-deploy-key", privateKey, repository, g.SSHUrl)
+	err = g.createKubernetesSecretForArgoCD(g.Namespace, deployKeySecretName(repository), privateKey, repository, g.SSHUrl)
 	if err != nil {
-		return resp, fmt.Errorf("failed to create kubernetes secret: %w", err)
+		return fmt.Errorf("failed to create kubernetes secret: %w", err)
 	}
 
-	return resp, nil
+	return nil
 }
 
-func createKubernetesSecretForArgoCD(namespace string, secretName, privateKey string, repo Repository, sshUrl string) error {
-	config, err := helpers.BuildKubeConfig()
-	if err != nil {
-		return fmt.Errorf("failed to build kubernetes config: %w", err)
-	}
-
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("failed to create kubernetes clientset: %w", err)
-	}
+func (g *Config) createKubernetesSecretForArgoCD(namespace string, secretName, privateKey string, repo Repository, sshUrl string) error {
+	labels := helpers.ManagedLabels("gitea")
+	labels["argocd.argoproj.io/secret-type"] = "repository"
 
 	secret := &corev1.Secret{
 		ObjectMeta: v1.ObjectMeta{
-			Name: secretName,
-			Labels: map[string]string{
-				"argocd.argoproj.io/secret-type": "repository",
-			},
+			Name:   secretName,
+			Labels: labels,
 		},
 		Data: map[string][]byte{
 			"url":           []byte(sshUrl + "/" + repo.Organization + "/" + repo.Name + ".git"),
@@ -77,7 +70,17 @@ func createKubernetesSecretForArgoCD(namespace string, secretName, privateKey st
 	}
 
 	if namespace == "" {
-		namespace = "argocd"
+		namespace = argoNamespace
+	}
+
+	if g.DryRun {
+		helpers.LogDryRun("CREATE", "secret "+namespace+"/"+secretName, helpers.SecretPayload(secret))
+		return nil
+	}
+
+	clientset, err := helpers.KubernetesClient()
+	if err != nil {
+		return err
 	}
 
 	_, err = clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, v1.CreateOptions{})
@@ -113,27 +116,26 @@ func (g *Config) createStageTemplate(stage Stage, gitOrg string, gitRepo string)
 	return tpl.String(), nil
 }
 
-func (g *Config) commitAppSet(stage Stage, gitOrg string, gitRepo string) error {
-	client, err := gitea.NewClient(g.Url, gitea.SetBasicAuth(g.Credentials.Username, g.Credentials.Password), gitea.SetHTTPClient(&http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: g.TLSConfig.InsecureSkipVerify,
-			},
-		},
-	}))
+func (g *Config) commitAppSet(client *gitea.Client, stage Stage, repo Repository, repoExists bool) (plan.Action, error) {
+	change, fileDetail, err := g.planAppSet(client, stage, repo, repoExists)
 	if err != nil {
-		return fmt.Errorf("failed to create gitea client: %w", err)
+		return "", err
 	}
-	content, err := g.createStageTemplate(stage, gitOrg, gitRepo)
+
+	if change.Action == plan.ActionNoOp {
+		log.Println(fmt.Sprintf("AppSet %s is up to date", change.Name))
+		g.state.Set(state.Resource{Kind: KindAppSet, Name: change.Name, SHA: fileDetail.SHA})
+		return change.Action, nil
+	}
+
+	content, err := g.createStageTemplate(stage, repo.Organization, repo.Name)
 	if err != nil {
-		return fmt.Errorf("failed to create stage template: %w", err)
+		return "", fmt.Errorf("failed to create stage template: %w", err)
 	}
 
 	encodedContent := base64.StdEncoding.EncodeToString([]byte(content))
 
-	// Check if the file exists
-	fileDetail, resp, _ := client.GetContents(gitOrg, gitRepo, "main", stage.Name+"/appset.yaml")
-	if resp.StatusCode == 404 {
+	if change.Action == plan.ActionCreate {
 		// File does not exist, create it
 		opts := gitea.CreateFileOptions{
 			FileOptions: gitea.FileOptions{
@@ -150,16 +152,23 @@ func (g *Config) commitAppSet(stage Stage, gitOrg string, gitRepo string) error
 			},
 			Content: encodedContent,
 		}
-		_, _, err := client.CreateFile(gitOrg, gitRepo, stage.Name+"/appset.yaml", opts)
+
+		if g.DryRun {
+			helpers.LogDryRun("CREATE", "file "+change.Name+"/appset.yaml", opts)
+			return change.Action, nil
+		}
+
+		file, _, err := client.CreateFile(repo.Organization, repo.Name, stage.Name+"/appset.yaml", opts)
 		if err != nil {
-			return fmt.Errorf("failed to create file: %w", err)
+			return "", fmt.Errorf("failed to create file: %w", err)
 		}
+		g.recordAppSet(change.Name, file)
 	} else {
 		// File exists, update it
 		opts := gitea.UpdateFileOptions{
 			FileOptions: gitea.FileOptions{
 				BranchName: "main",
-				Message:    "Initial commit of AppSet " + stage.Name,
+				Message:    "Update AppSet " + stage.Name,
 				Author: gitea.Identity{
 					Name:  "Deployer",
 					Email: "deploy@on-clouds.at",
@@ -172,11 +181,25 @@ func (g *Config) commitAppSet(stage Stage, gitOrg string, gitRepo string) error
 			Content: encodedContent,
 			SHA:     fileDetail.SHA,
 		}
-		_, _, err := client.UpdateFile(gitOrg, gitRepo, stage.Name+"/appset.yaml", opts)
+
+		if g.DryRun {
+			helpers.LogDryRun("UPDATE", "file "+change.Name+"/appset.yaml", opts)
+			return change.Action, nil
+		}
+
+		file, _, err := client.UpdateFile(repo.Organization, repo.Name, stage.Name+"/appset.yaml", opts)
 		if err != nil {
-			return fmt.Errorf("failed to update file: %w", err)
+			return "", fmt.Errorf("failed to update file: %w", err)
 		}
+		g.recordAppSet(change.Name, file)
 	}
 
-	return nil
+	return change.Action, nil
+}
+
+func (g *Config) recordAppSet(name string, file *gitea.FileResponse) {
+	if file == nil || file.Content == nil {
+		return
+	}
+	g.state.Set(state.Resource{Kind: KindAppSet, Name: name, SHA: file.Content.SHA})
 }
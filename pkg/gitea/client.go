@@ -0,0 +1,114 @@
+package gitea
+
+import (
+	"code.gitea.io/sdk/gitea"
+	"crypto/tls"
+	"fmt"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/state"
+	"net/http"
+)
+
+const (
+	AuthBasic  = "basic"
+	AuthToken  = "token"
+	AuthOAuth2 = "oauth2"
+)
+
+type Credentials struct {
+	// Method selects how platformer authenticates: basic (default), token or oauth2.
+	Method   string         `yaml:"method,omitempty"`
+	Username string         `yaml:"username"`
+	Password helpers.Secret `yaml:"password"`
+	Token    helpers.Secret `yaml:"token,omitempty"`
+	// Sudo impersonates the given user for all requests. Requires an admin account.
+	Sudo string `yaml:"sudo,omitempty"`
+}
+
+// SetState records the deploy keys and ApplicationSet files platformer manages in s.
+func (g *Config) SetState(s *state.State) {
+	g.state = s
+}
+
+// bearerTransport authenticates requests with an OAuth2 access token.
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+func (g *Config) transport() http.RoundTripper {
+	g.clientMu.Lock()
+	defer g.clientMu.Unlock()
+
+	if g.httpTransport == nil {
+		g.httpTransport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: g.TLSConfig.InsecureSkipVerify,
+			},
+		}
+	}
+	return g.httpTransport
+}
+
+func (g *Config) authOptions() ([]gitea.ClientOption, http.RoundTripper, error) {
+	transport := g.transport()
+	var options []gitea.ClientOption
+
+	switch g.Credentials.Method {
+	case "", AuthBasic:
+		options = append(options, gitea.SetBasicAuth(g.Credentials.Username, g.Credentials.Password.Value))
+	case AuthToken:
+		if g.Credentials.Token.Value == "" {
+			return nil, nil, fmt.Errorf("gitea credentials: token is required for method %s", AuthToken)
+		}
+		options = append(options, gitea.SetToken(g.Credentials.Token.Value))
+	case AuthOAuth2:
+		if g.Credentials.Token.Value == "" {
+			return nil, nil, fmt.Errorf("gitea credentials: token is required for method %s", AuthOAuth2)
+		}
+		transport = &bearerTransport{token: g.Credentials.Token.Value, base: transport}
+	default:
+		return nil, nil, fmt.Errorf("gitea credentials: unknown method %q", g.Credentials.Method)
+	}
+
+	if g.Credentials.Sudo != "" {
+		options = append(options, gitea.SetSudo(g.Credentials.Sudo))
+	}
+	return options, transport, nil
+}
+
+// client returns the Gitea client shared by all operations. It is built on first use
+// so that connections are reused across organizations and repositories.
+func (g *Config) client() (*gitea.Client, error) {
+	g.clientMu.Lock()
+	client := g.giteaClient
+	g.clientMu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	options, transport, err := g.authOptions()
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, gitea.SetHTTPClient(&http.Client{Transport: transport}))
+
+	client, err = gitea.NewClient(g.Url, options...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Gitea client: %w", err)
+	}
+
+	g.clientMu.Lock()
+	defer g.clientMu.Unlock()
+	if g.giteaClient == nil {
+		g.giteaClient = client
+	}
+	return g.giteaClient, nil
+}
@@ -0,0 +1,105 @@
+package gitea
+
+import (
+	"code.gitea.io/sdk/gitea"
+	"context"
+	"fmt"
+	"github.com/thschue/platformer/pkg/plan"
+	"slices"
+)
+
+// planDestroy lists the declared Gitea resources which exist, in the order they have
+// to be deleted. The ApplicationSets are deleted with their repository.
+func (g *Config) planDestroy(client *gitea.Client) ([]deletion, error) {
+	secrets, err := g.argoSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	var deletions []deletion
+	for _, repo := range g.Repositories {
+		_, resp, err := client.GetRepo(repo.Organization, repo.Name)
+		if resp != nil && resp.StatusCode == 404 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting repository %s: %w", repositoryName(repo), err)
+		}
+
+		keys, _, err := client.ListDeployKeys(repo.Organization, repo.Name, gitea.ListDeployKeysOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error listing deploy keys of %s: %w", repositoryName(repo), err)
+		}
+		org, name := repo.Organization, repo.Name
+		for _, key := range keys {
+			if key.Title != deployKeyTitle {
+				continue
+			}
+			id := key.ID
+			deletions = append(deletions, deletion{KindDeployKey, repositoryName(repo), func() error {
+				_, err := client.DeleteDeployKey(org, name, id)
+				return err
+			}})
+		}
+
+		if slices.Contains(secrets, deployKeySecretName(repo)) {
+			deletions = append(deletions, g.secretDeletion(deployKeySecretName(repo)))
+		}
+
+		deletions = append(deletions, deletion{KindRepository, repositoryName(repo), func() error {
+			_, err := client.DeleteRepo(org, name)
+			return err
+		}})
+	}
+
+	for _, org := range g.Orgs {
+		_, resp, err := client.GetOrg(org.Name)
+		if resp != nil && resp.StatusCode == 404 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting organization %s: %w", org.Name, err)
+		}
+
+		name := org.Name
+		deletions = append(deletions, deletion{KindOrganization, name, func() error {
+			_, err := client.DeleteOrg(name)
+			return err
+		}})
+	}
+	return deletions, nil
+}
+
+// PlanDestroy lists the deletions Destroy would apply.
+func (g *Config) PlanDestroy() ([]plan.Change, error) {
+	client, err := g.client()
+	if err != nil {
+		return nil, err
+	}
+
+	deletions, err := g.planDestroy(client)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []plan.Change
+	for _, d := range deletions {
+		changes = append(changes, plan.Change{Kind: d.kind, Name: d.name, Action: plan.ActionDelete})
+	}
+	return changes, nil
+}
+
+// Destroy deletes every declared organization and repository, together with the deploy
+// keys and their ArgoCD secrets.
+func (g *Config) Destroy(ctx context.Context) ([]plan.Change, error) {
+	client, err := g.client()
+	if err != nil {
+		return nil, err
+	}
+
+	deletions, err := g.planDestroy(client)
+	if err != nil {
+		return nil, err
+	}
+	return g.applyDeletions(deletions, func(string, string) bool { return ctx.Err() == nil })
+}
@@ -0,0 +1,124 @@
+package gitea
+
+import (
+	"code.gitea.io/sdk/gitea"
+	"encoding/base64"
+	"fmt"
+	"github.com/thschue/platformer/pkg/helpers"
+	"gopkg.in/yaml.v3"
+	"sort"
+)
+
+// appSet holds the fields of a generated ApplicationSet which come from the stage.
+type appSet struct {
+	Spec struct {
+		Template struct {
+			Spec struct {
+				Project     string `yaml:"project"`
+				Destination struct {
+					Name string `yaml:"name"`
+				} `yaml:"destination"`
+			} `yaml:"spec"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+// Export replaces the declared organizations and repositories with the ones the
+// authenticated user can see. The stages of a repository are recovered from the
+// ApplicationSets in its top-level directories.
+func (g *Config) Export() error {
+	client, err := g.client()
+	if err != nil {
+		return err
+	}
+
+	orgs, err := listOrganizations(client)
+	if err != nil {
+		return err
+	}
+
+	g.Orgs = nil
+	g.Repositories = nil
+	for _, org := range orgs {
+		g.Orgs = append(g.Orgs, Organization{
+			Name:        org.UserName,
+			Description: helpers.UnmarkManaged(org.Description),
+			Visibility:  gitea.VisibleType(org.Visibility),
+		})
+
+		repos, err := listRepositories(client, org.UserName)
+		if err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			stages, err := exportStages(client, org.UserName, repo.Name)
+			if err != nil {
+				return err
+			}
+			g.Repositories = append(g.Repositories, Repository{
+				Name:         repo.Name,
+				Organization: org.UserName,
+				Description:  helpers.UnmarkManaged(repo.Description),
+				Private:      repo.Private,
+				Stages:       stages,
+			})
+		}
+	}
+
+	sort.Slice(g.Orgs, func(i, j int) bool { return g.Orgs[i].Name < g.Orgs[j].Name })
+	sort.Slice(g.Repositories, func(i, j int) bool {
+		return repositoryName(g.Repositories[i]) < repositoryName(g.Repositories[j])
+	})
+	return nil
+}
+
+func exportStages(client *gitea.Client, org string, repo string) ([]Stage, error) {
+	entries, resp, err := client.ListContents(org, repo, "main", "")
+	if resp != nil && resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing contents of %s/%s: %w", org, repo, err)
+	}
+
+	var stages []Stage
+	for _, entry := range entries {
+		if entry.Type != "dir" {
+			continue
+		}
+
+		file, resp, err := client.GetContents(org, repo, "main", entry.Name+"/appset.yaml")
+		if resp != nil && resp.StatusCode == 404 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting appset %s/%s/%s: %w", org, repo, entry.Name, err)
+		}
+
+		var content []byte
+		if file.Content != nil {
+			content, err = base64.StdEncoding.DecodeString(*file.Content)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding appset %s/%s/%s: %w", org, repo, entry.Name, err)
+			}
+		}
+
+		var set appSet
+		err = yaml.Unmarshal(content, &set)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing appset %s/%s/%s: %w", org, repo, entry.Name, err)
+		}
+
+		// Defaults are left out, as they would be when written by hand.
+		stage := Stage{Name: entry.Name}
+		defaults := stageWithDefaults(stage)
+		if project := set.Spec.Template.Spec.Project; project != defaults.ArgoProject {
+			stage.ArgoProject = project
+		}
+		if cluster := set.Spec.Template.Spec.Destination.Name; cluster != defaults.ArgoCluster {
+			stage.ArgoCluster = cluster
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
@@ -0,0 +1,47 @@
+package gitea
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/thschue/platformer/pkg/plan"
+	"testing"
+)
+
+// TestExportPlanRoundTrip checks that the exported config of resources platformer did
+// not create plans no changes.
+func TestExportPlanRoundTrip(t *testing.T) {
+	responses := map[string]string{
+		"GET /user/orgs":                `[{"id": 1, "username": "team", "description": "Team A", "visibility": "private"}]`,
+		"GET /orgs/team":                `{"id": 1, "username": "team", "description": "Team A", "visibility": "private"}`,
+		"GET /orgs/team/repos":          `[{"id": 2, "name": "app", "description": "The app", "private": true}]`,
+		"GET /repos/team/app":           `{"id": 2, "name": "app", "description": "The app", "private": true}`,
+		"GET /repos/team/app/keys":      `[{"id": 3, "title": "GitOps Deployment Key"}]`,
+		"GET /repos/team/app/contents/": `[{"name": "dev", "path": "dev", "type": "dir"}, {"name": "README.md", "path": "README.md", "type": "file"}]`,
+	}
+	g, _ := fakeGitea(t, responses)
+
+	appset, err := g.createStageTemplate(stageWithDefaults(Stage{Name: "dev", ArgoProject: "platform"}), "team", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses["GET /repos/team/app/contents/dev/appset.yaml"] = fmt.Sprintf(`{"name": "appset.yaml", "path": "dev/appset.yaml", "sha": "a1", "type": "file", "content": "%s"}`,
+		base64.StdEncoding.EncodeToString([]byte(appset)))
+
+	err = g.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := g.Plan(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 {
+		t.Errorf("expected 4 changes, got %d: %+v", len(changes), changes)
+	}
+	for _, change := range changes {
+		if change.Action != plan.ActionNoOp {
+			t.Errorf("expected %s %s to be %s, got %s with %+v", change.Kind, change.Name, plan.ActionNoOp, change.Action, change.Diff)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package gitea
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeGitea serves the given bodies by "METHOD /path" below /api/v1 and records the
+// requests it received. Unknown requests get a 404.
+func fakeGitea(t *testing.T, responses map[string]string) (*Config, *[]string) {
+	t.Helper()
+
+	if _, ok := responses["GET /version"]; !ok {
+		responses["GET /version"] = `{"version": "1.21.0"}`
+	}
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path[len("/api/v1"):]
+		requests = append(requests, key)
+
+		body, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return &Config{Url: server.URL, SSHUrl: "ssh://git@gitea:22"}, &requests
+}
@@ -0,0 +1,69 @@
+package gitea
+
+import (
+	"fmt"
+	"github.com/thschue/platformer/pkg/graph"
+	"github.com/thschue/platformer/pkg/plan"
+)
+
+// Resources returns the declared Gitea resources accepted by the filter together with
+// their dependencies. A repository node also covers its ApplicationSets and deploy key.
+func (g *Config) Resources(filter func(kind string, name string) bool) []graph.Node {
+	var nodes []graph.Node
+
+	for _, org := range g.Orgs {
+		if !filter(KindOrganization, org.Name) {
+			continue
+		}
+		org := org
+		nodes = append(nodes, graph.Node{
+			Kind: KindOrganization,
+			Name: org.Name,
+			Apply: func() (plan.Action, error) {
+				action, err := g.CreateOrganization(org)
+				if err != nil {
+					return "", fmt.Errorf("error creating organization: %w", err)
+				}
+				return action, nil
+			},
+		})
+	}
+
+	for _, repo := range g.Repositories {
+		if !repositoryFiltered(repo, filter) {
+			continue
+		}
+		repo := repo
+		nodes = append(nodes, graph.Node{
+			Kind: KindRepository,
+			Name: repositoryName(repo),
+			DependsOn: []string{
+				graph.ID(KindOrganization, repo.Organization),
+			},
+			Apply: func() (plan.Action, error) {
+				action, err := g.CreateRepository(repo.Organization, repo)
+				if err != nil {
+					return "", fmt.Errorf("error creating repository: %w", err)
+				}
+				return action, nil
+			},
+		})
+	}
+
+	return nodes
+}
+
+// repositoryFiltered reports whether the filter accepts the repository, its deploy key
+// or one of its ApplicationSets, which are all applied by the repository node.
+func repositoryFiltered(repo Repository, filter func(kind string, name string) bool) bool {
+	name := repositoryName(repo)
+	if filter(KindRepository, name) || filter(KindDeployKey, name) {
+		return true
+	}
+	for _, stage := range repo.Stages {
+		if filter(KindAppSet, name+"/"+stage.Name) {
+			return true
+		}
+	}
+	return false
+}
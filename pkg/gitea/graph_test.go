@@ -0,0 +1,48 @@
+package gitea
+
+import (
+	"github.com/thschue/platformer/pkg/plan"
+	"testing"
+)
+
+func TestResourcesFilter(t *testing.T) {
+	g := &Config{
+		Orgs: []Organization{{Name: "org"}, {Name: "org2"}},
+		Repositories: []Repository{
+			{Organization: "org", Name: "app", Stages: []Stage{{Name: "dev"}}},
+			{Organization: "org", Name: "api"},
+			{Organization: "org2", Name: "app"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		changes []plan.Change
+		nodes   []string
+	}{
+		{name: "organization", changes: []plan.Change{{Kind: KindOrganization, Name: "org"}}, nodes: []string{"org"}},
+		{name: "repository", changes: []plan.Change{{Kind: KindRepository, Name: "org/app"}}, nodes: []string{"org/app"}},
+		{name: "appset", changes: []plan.Change{{Kind: KindAppSet, Name: "org/app/dev"}}, nodes: []string{"org/app"}},
+		{name: "deploy key", changes: []plan.Change{{Kind: KindDeployKey, Name: "org/api"}}, nodes: []string{"org/api"}},
+		{name: "other kind with the same name", changes: []plan.Change{{Kind: "harbor/project", Name: "org/app"}}},
+		{name: "appset of another stage", changes: []plan.Change{{Kind: KindAppSet, Name: "org/app/prod"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := plan.New("checksum")
+			for _, change := range tt.changes {
+				change.Action = plan.ActionUpdate
+				p.Add(change)
+			}
+
+			var nodes []string
+			for _, node := range g.Resources(p.Includes) {
+				nodes = append(nodes, node.Name)
+			}
+			if len(nodes) != len(tt.nodes) || (len(nodes) > 0 && nodes[0] != tt.nodes[0]) {
+				t.Errorf("expected nodes %v, got %v", tt.nodes, nodes)
+			}
+		})
+	}
+}
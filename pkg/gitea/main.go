@@ -2,139 +2,179 @@ package gitea
 
 import (
 	"code.gitea.io/sdk/gitea"
-	"crypto/tls"
-	"errors"
+	"encoding/json"
 	"fmt"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
-func (h *Config) IsAvailable() (bool, error) {
-	_, err := http.NewRequest("GET", h.Url, nil)
+const probeTimeout = 10 * time.Second
+
+// IsAvailable checks that the Gitea API answers with its version.
+func (g *Config) IsAvailable() (bool, error) {
+	client := &http.Client{Transport: g.transport(), Timeout: probeTimeout}
+
+	resp, err := client.Get(strings.TrimSuffix(g.Url, "/") + "/api/v1/version")
 	if err != nil {
-		fmt.Println(err)
 		return false, err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("version endpoint returned status code %d", resp.StatusCode)
+	}
+
+	var version struct {
+		Version string `json:"version"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&version)
+	if err != nil || version.Version == "" {
+		return false, fmt.Errorf("version endpoint returned an unexpected response: %v", err)
+	}
 	return true, nil
 }
 
-func (g *Config) organizationExists(orgName string) bool {
-	client, err := gitea.NewClient(g.Url, gitea.SetBasicAuth(g.Credentials.Username, g.Credentials.Password), gitea.SetHTTPClient(&http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: g.TLSConfig.InsecureSkipVerify,
-			},
-		},
-	}))
-
-	_, resp, err := client.GetOrg(orgName)
+func (g *Config) CreateOrganization(organization Organization) (plan.Action, error) {
+	client, err := g.client()
 	if err != nil {
-		if resp != nil && resp.StatusCode == 404 {
-			return false // Organization does not exist
-		}
-		log.Fatalf("Failed to get organization: %v", err)
+		return "", err
 	}
 
-	return true // Organization exists
-}
+	change, err := planOrganization(client, organization)
+	if err != nil {
+		return "", err
+	}
 
-func (g *Config) CreateOrganization(organization Organization) error {
+	switch change.Action {
+	case plan.ActionCreate:
+		orgOption := gitea.CreateOrgOption{
+			Name:        organization.Name,
+			Description: helpers.MarkManaged(organization.Description),
+			Visibility:  organization.Visibility,
+		}
 
-	client, err := gitea.NewClient(g.Url, gitea.SetBasicAuth(g.Credentials.Username, g.Credentials.Password), gitea.SetHTTPClient(&http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: g.TLSConfig.InsecureSkipVerify,
-			},
-		},
-	}))
+		if g.DryRun {
+			helpers.LogDryRun("CREATE", "organization "+organization.Name, orgOption)
+			return change.Action, nil
+		}
 
-	if err != nil {
-		return fmt.Errorf("error creating Gitea client: %w", err)
-	}
+		org, _, err := client.CreateOrg(orgOption)
+		if err != nil {
+			return "", fmt.Errorf("error creating organization: %w", err)
+		}
+		log.Println(fmt.Sprintf("Organization %s created", org.UserName))
+	case plan.ActionUpdate:
+		orgOption := gitea.EditOrgOption{
+			Description: helpers.MarkManaged(organization.Description),
+			Visibility:  organization.Visibility,
+		}
 
-	orgOption := gitea.CreateOrgOption{
-		Name:       organization.Name,
-		Visibility: organization.Visibility,
-	}
+		if g.DryRun {
+			helpers.LogDryRun("UPDATE", "organization "+organization.Name, orgOption)
+			return change.Action, nil
+		}
 
-	org, _, err := client.CreateOrg(orgOption)
-	if err != nil {
-		if !g.organizationExists(organization.Name) {
-			return fmt.Errorf("error creating organization: %w", err)
+		_, err := client.EditOrg(organization.Name, orgOption)
+		if err != nil {
+			return "", fmt.Errorf("error updating organization: %w", err)
 		}
+		log.Println(fmt.Sprintf("Organization %s updated", organization.Name))
+	default:
 		log.Println(fmt.Sprintf("Organization %s already exists", organization.Name))
-		return nil
 	}
-	log.Println(fmt.Sprintf("Organization %s created", org.UserName))
-	return nil
+	g.state.Set(state.Resource{Kind: KindOrganization, Name: organization.Name})
+	return change.Action, nil
 }
 
-func (g *Config) repositoryExists(repo Repository) bool {
-	client, err := gitea.NewClient(g.Url, gitea.SetBasicAuth(g.Credentials.Username, g.Credentials.Password), gitea.SetHTTPClient(&http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: g.TLSConfig.InsecureSkipVerify,
-			},
-		},
-	}))
-
-	_, resp, err := client.GetRepo(repo.Organization, repo.Name)
+func (g *Config) CreateRepository(organization string, repo Repository) (plan.Action, error) {
+	client, err := g.client()
 	if err != nil {
-		if resp != nil && resp.StatusCode == 404 {
-			return false // Repository does not exist
-		}
-		return false
+		return "", err
 	}
 
-	return true // Repository exists
-}
-
-func (g *Config) CreateRepository(organization string, repo Repository) error {
-	client, err := gitea.NewClient(g.Url, gitea.SetBasicAuth(g.Credentials.Username, g.Credentials.Password), gitea.SetHTTPClient(&http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: g.TLSConfig.InsecureSkipVerify,
-			},
-		},
-	}))
+	repo.Organization = organization
+	description := helpers.MarkManaged(repo.Description)
+	change, err := planRepository(client, repo)
 	if err != nil {
-		return fmt.Errorf("error creating Gitea client: %w", err)
+		return "", err
 	}
 
-	repoOption := gitea.CreateRepoOption{
-		Name:          repo.Name,
-		Private:       repo.Private,
-		AutoInit:      true,
-		DefaultBranch: "main",
-	}
+	// Changes to the ApplicationSets or the deploy key count as an update of the repository.
+	action := change.Action
+	exists := true
+	switch change.Action {
+	case plan.ActionCreate:
+		repoOption := gitea.CreateRepoOption{
+			Name:          repo.Name,
+			Description:   description,
+			Private:       repo.Private,
+			AutoInit:      true,
+			DefaultBranch: "main",
+		}
 
-	_, resp, err := client.CreateOrgRepo(organization, repoOption)
-	if err != nil && resp.StatusCode != 409 {
-		if !g.repositoryExists(repo) {
-			return fmt.Errorf("error creating repository: %w", err)
+		if g.DryRun {
+			helpers.LogDryRun("CREATE", "repository "+repositoryName(repo), repoOption)
+			exists = false
+			break
 		}
-		log.Println(fmt.Sprintf("Repository %s already exists", repo.Name))
-	}
 
-	for _, stage := range repo.Stages {
-		if stage.ArgoProject == "" {
-			stage.ArgoProject = "default"
+		_, _, err := client.CreateOrgRepo(organization, repoOption)
+		if err != nil {
+			return "", fmt.Errorf("error creating repository: %w", err)
+		}
+		log.Println(fmt.Sprintf("Repository %s created", repo.Name))
+	case plan.ActionUpdate:
+		repoOption := gitea.EditRepoOption{
+			Description: &description,
+			Private:     &repo.Private,
 		}
 
-		if stage.ArgoCluster == "" {
-			stage.ArgoCluster = "https://kubernetes.default.svc"
+		if g.DryRun {
+			helpers.LogDryRun("UPDATE", "repository "+repositoryName(repo), repoOption)
+			break
+		}
+
+		_, _, err := client.EditRepo(organization, repo.Name, repoOption)
+		if err != nil {
+			return "", fmt.Errorf("error updating repository: %w", err)
 		}
-		err = g.commitAppSet(stage, organization, repo.Name)
+		log.Println(fmt.Sprintf("Repository %s updated", repo.Name))
+	default:
+		log.Println(fmt.Sprintf("Repository %s already exists", repo.Name))
+	}
+	if !g.DryRun {
+		g.state.Set(state.Resource{Kind: KindRepository, Name: repositoryName(repo)})
+	}
+
+	for _, stage := range repo.Stages {
+		appSetAction, err := g.commitAppSet(client, stageWithDefaults(stage), repo, exists)
 		if err != nil {
-			return fmt.Errorf("error committing appset: %w", err)
+			return "", fmt.Errorf("error committing appset: %w", err)
+		}
+		if appSetAction != plan.ActionNoOp && action == plan.ActionNoOp {
+			action = plan.ActionUpdate
 		}
 	}
 
-	resp, err = g.createDeployKey(repo)
-	fmt.Println(err)
-	if err != nil && !errors.Is(err, fmt.Errorf("failed to create deploy key: A key with the same name already exists")) {
-		return fmt.Errorf("error creating deploy key: %w", err)
+	change, err = planDeployKey(client, repo, exists)
+	if err != nil {
+		return "", err
+	}
+
+	if change.Action == plan.ActionCreate {
+		err = g.createDeployKey(client, repo)
+		if err != nil {
+			return "", fmt.Errorf("error creating deploy key: %w", err)
+		}
+		if action == plan.ActionNoOp {
+			action = plan.ActionUpdate
+		}
 	}
 
-	return nil
+	return action, nil
 }
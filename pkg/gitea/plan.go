@@ -0,0 +1,205 @@
+package gitea
+
+import (
+	"code.gitea.io/sdk/gitea"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+)
+
+const (
+	KindOrganization = "gitea/organization"
+	KindRepository   = "gitea/repository"
+	KindAppSet       = "gitea/appset"
+	KindDeployKey    = "gitea/deploy-key"
+)
+
+const deployKeyTitle = "GitOps Deployment Key"
+
+// Plan compares the declared Gitea resources with the live state of the Gitea instance.
+// With prune, undeclared resources created by platformer are planned for deletion.
+// Descriptions are compared without the ownership marker, which is only added with the
+// next update of an adopted resource.
+func (g *Config) Plan(prune bool) ([]plan.Change, error) {
+	client, err := g.client()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []plan.Change
+	for _, org := range g.Orgs {
+		change, err := planOrganization(client, org)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	for _, repo := range g.Repositories {
+		change, err := planRepository(client, repo)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+		exists := change.Action != plan.ActionCreate
+
+		for _, stage := range repo.Stages {
+			change, _, err := g.planAppSet(client, stageWithDefaults(stage), repo, exists)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, change)
+		}
+
+		change, err = planDeployKey(client, repo, exists)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	if prune {
+		deletions, err := g.planPrune(client)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deletions {
+			changes = append(changes, plan.Change{Kind: d.kind, Name: d.name, Action: plan.ActionDelete})
+		}
+	}
+	return changes, nil
+}
+
+func repositoryName(repo Repository) string {
+	return repo.Organization + "/" + repo.Name
+}
+
+func stageWithDefaults(stage Stage) Stage {
+	if stage.ArgoProject == "" {
+		stage.ArgoProject = "default"
+	}
+
+	if stage.ArgoCluster == "" {
+		stage.ArgoCluster = "https://kubernetes.default.svc"
+	}
+	return stage
+}
+
+func planOrganization(client *gitea.Client, organization Organization) (plan.Change, error) {
+	visibility := organization.Visibility
+	if visibility == "" {
+		visibility = gitea.VisibleTypePublic
+	}
+	desired := map[string]interface{}{
+		"description": organization.Description,
+		"visibility":  string(visibility),
+	}
+
+	org, resp, err := client.GetOrg(organization.Name)
+	if resp != nil && resp.StatusCode == 404 {
+		return plan.NewChange(KindOrganization, organization.Name, nil, desired), nil
+	}
+	if err != nil {
+		return plan.Change{}, fmt.Errorf("error getting organization %s: %w", organization.Name, err)
+	}
+
+	live := map[string]interface{}{
+		"description": helpers.UnmarkManaged(org.Description),
+		"visibility":  org.Visibility,
+	}
+	return plan.NewChange(KindOrganization, organization.Name, live, desired), nil
+}
+
+func planRepository(client *gitea.Client, repo Repository) (plan.Change, error) {
+	desired := map[string]interface{}{
+		"private":     repo.Private,
+		"description": repo.Description,
+	}
+
+	existing, resp, err := client.GetRepo(repo.Organization, repo.Name)
+	if resp != nil && resp.StatusCode == 404 {
+		return plan.NewChange(KindRepository, repositoryName(repo), nil, desired), nil
+	}
+	if err != nil {
+		return plan.Change{}, fmt.Errorf("error getting repository %s: %w", repositoryName(repo), err)
+	}
+
+	live := map[string]interface{}{
+		"private":     existing.Private,
+		"description": helpers.UnmarkManaged(existing.Description),
+	}
+	return plan.NewChange(KindRepository, repositoryName(repo), live, desired), nil
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func (g *Config) planAppSet(client *gitea.Client, stage Stage, repo Repository, repoExists bool) (plan.Change, *gitea.ContentsResponse, error) {
+	name := repositoryName(repo) + "/" + stage.Name
+
+	content, err := g.createStageTemplate(stage, repo.Organization, repo.Name)
+	if err != nil {
+		return plan.Change{}, nil, fmt.Errorf("failed to create stage template: %w", err)
+	}
+	desired := map[string]interface{}{
+		"path":     stage.Name + "/appset.yaml",
+		"checksum": checksum(content),
+	}
+
+	if !repoExists {
+		return plan.NewChange(KindAppSet, name, nil, desired), nil, nil
+	}
+
+	fileDetail, resp, err := client.GetContents(repo.Organization, repo.Name, "main", stage.Name+"/appset.yaml")
+	if resp != nil && resp.StatusCode == 404 {
+		return plan.NewChange(KindAppSet, name, nil, desired), nil, nil
+	}
+	if err != nil {
+		return plan.Change{}, nil, fmt.Errorf("error getting appset %s: %w", name, err)
+	}
+
+	var liveContent []byte
+	if fileDetail.Content != nil {
+		liveContent, err = base64.StdEncoding.DecodeString(*fileDetail.Content)
+		if err != nil {
+			return plan.Change{}, nil, fmt.Errorf("error decoding appset %s: %w", name, err)
+		}
+	}
+
+	live := map[string]interface{}{
+		"path":     fileDetail.Path,
+		"checksum": checksum(string(liveContent)),
+	}
+	return plan.NewChange(KindAppSet, name, live, desired), fileDetail, nil
+}
+
+func planDeployKey(client *gitea.Client, repo Repository, repoExists bool) (plan.Change, error) {
+	desired := map[string]interface{}{
+		"title": deployKeyTitle,
+	}
+
+	if !repoExists {
+		return plan.NewChange(KindDeployKey, repositoryName(repo), nil, desired), nil
+	}
+
+	keys, _, err := client.ListDeployKeys(repo.Organization, repo.Name, gitea.ListDeployKeysOptions{})
+	if err != nil {
+		return plan.Change{}, fmt.Errorf("error listing deploy keys of %s: %w", repositoryName(repo), err)
+	}
+
+	for _, key := range keys {
+		if key.Title != deployKeyTitle {
+			continue
+		}
+		live := map[string]interface{}{
+			"title": key.Title,
+		}
+		return plan.NewChange(KindDeployKey, repositoryName(repo), live, desired), nil
+	}
+	return plan.NewChange(KindDeployKey, repositoryName(repo), nil, desired), nil
+}
@@ -0,0 +1,42 @@
+package gitea
+
+import (
+	"encoding/json"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"testing"
+)
+
+func TestPlanOrganizationDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		live        string
+		description string
+		action      plan.Action
+	}{
+		{name: "same description", live: helpers.MarkManaged("Team A"), description: "Team A", action: plan.ActionNoOp},
+		{name: "marker only", live: helpers.MarkManaged(""), action: plan.ActionNoOp},
+		{name: "changed description", live: helpers.MarkManaged("Team A"), description: "Team B", action: plan.ActionUpdate},
+		{name: "adopted organization", live: "Team A", description: "Team A", action: plan.ActionNoOp},
+		{name: "adopted organization with changes", live: "Team A", description: "Team B", action: plan.ActionUpdate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org, _ := json.Marshal(map[string]string{"username": "team", "description": tt.live, "visibility": "public"})
+			g, _ := fakeGitea(t, map[string]string{"GET /orgs/team": string(org)})
+			client, err := g.client()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			change, err := planOrganization(client, Organization{Name: "team", Description: tt.description})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if change.Action != tt.action {
+				t.Errorf("expected %s, got %s with %+v", tt.action, change.Action, change.Diff)
+			}
+		})
+	}
+}
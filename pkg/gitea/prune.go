@@ -0,0 +1,304 @@
+package gitea
+
+import (
+	"code.gitea.io/sdk/gitea"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"log"
+)
+
+const KindArgoSecret = "gitea/argocd-secret"
+
+const listPageSize = 50
+
+type deletion struct {
+	kind   string
+	name   string
+	delete func() error
+}
+
+// planPrune lists the resources platformer created in Gitea which are no longer declared,
+// in the order they have to be deleted. Organizations and repositories are only
+// considered when they carry the ownership marker in their description or are recorded
+// in the state.
+func (g *Config) planPrune(client *gitea.Client) ([]deletion, error) {
+	var deletions []deletion
+
+	for _, repo := range g.Repositories {
+		appSets, err := g.planPruneAppSets(client, repo)
+		if err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, appSets...)
+	}
+
+	orgs, err := listOrganizations(client)
+	if err != nil {
+		return nil, err
+	}
+
+	declaredRepos := map[string]bool{}
+	for _, repo := range g.Repositories {
+		declaredRepos[repositoryName(repo)] = true
+	}
+	declaredOrgs := map[string]bool{}
+	for _, org := range g.Orgs {
+		declaredOrgs[org.Name] = true
+	}
+
+	for _, org := range orgs {
+		repos, err := listRepositories(client, org.UserName)
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range repos {
+			_, recorded := g.state.Get(KindRepository, org.UserName+"/"+repo.Name)
+			if declaredRepos[org.UserName+"/"+repo.Name] || !(recorded || helpers.IsManaged(repo.Description)) {
+				continue
+			}
+			owner, name := org.UserName, repo.Name
+			deletions = append(deletions, deletion{KindRepository, owner + "/" + name, func() error {
+				_, err := client.DeleteRepo(owner, name)
+				return err
+			}})
+		}
+	}
+
+	for _, org := range orgs {
+		_, recorded := g.state.Get(KindOrganization, org.UserName)
+		if declaredOrgs[org.UserName] || !(recorded || helpers.IsManaged(org.Description)) {
+			continue
+		}
+		name := org.UserName
+		deletions = append(deletions, deletion{KindOrganization, name, func() error {
+			_, err := client.DeleteOrg(name)
+			return err
+		}})
+	}
+
+	secrets, err := g.planPruneSecrets()
+	if err != nil {
+		return nil, err
+	}
+	return append(deletions, secrets...), nil
+}
+
+// planPruneAppSets finds the appsets of stages which were removed from a declared
+// repository. Appset files written by hand or by other tools are left alone.
+func (g *Config) planPruneAppSets(client *gitea.Client, repo Repository) ([]deletion, error) {
+	entries, resp, err := client.ListContents(repo.Organization, repo.Name, "main", "")
+	if resp != nil && resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing contents of %s: %w", repositoryName(repo), err)
+	}
+
+	declared := map[string]bool{}
+	for _, stage := range repo.Stages {
+		declared[stage.Name] = true
+	}
+
+	var deletions []deletion
+	for _, entry := range entries {
+		if entry.Type != "dir" || declared[entry.Name] {
+			continue
+		}
+
+		path := entry.Name + "/appset.yaml"
+		file, resp, err := client.GetContents(repo.Organization, repo.Name, "main", path)
+		if resp != nil && resp.StatusCode == 404 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting %s of %s: %w", path, repositoryName(repo), err)
+		}
+		owned, err := g.ownsAppSet(repo, entry.Name, file)
+		if err != nil {
+			return nil, err
+		}
+		if !owned {
+			continue
+		}
+
+		opts := gitea.DeleteFileOptions{
+			FileOptions: gitea.FileOptions{
+				BranchName: "main",
+				Message:    "Remove AppSet " + entry.Name,
+				Author: gitea.Identity{
+					Name:  "Deployer",
+					Email: "deploy@on-clouds.at",
+				},
+				Committer: gitea.Identity{
+					Name:  "Deployer",
+					Email: "deploy@on-clouds.at",
+				},
+			},
+			SHA: file.SHA,
+		}
+		org, name := repo.Organization, repo.Name
+		deletions = append(deletions, deletion{KindAppSet, repositoryName(repo) + "/" + entry.Name, func() error {
+			_, err := client.DeleteFile(org, name, path, opts)
+			return err
+		}})
+	}
+	return deletions, nil
+}
+
+// ownsAppSet reports whether platformer wrote an appset file, either because its SHA is
+// the one recorded in the state or because it is the template generated for the stage.
+func (g *Config) ownsAppSet(repo Repository, stage string, file *gitea.ContentsResponse) (bool, error) {
+	recorded, ok := g.state.Get(KindAppSet, repositoryName(repo)+"/"+stage)
+	if ok && recorded.SHA != "" && recorded.SHA == file.SHA {
+		return true, nil
+	}
+	if file.Content == nil {
+		return false, nil
+	}
+
+	content, err := base64.StdEncoding.DecodeString(*file.Content)
+	if err != nil {
+		return false, fmt.Errorf("error decoding %s of %s: %w", file.Path, repositoryName(repo), err)
+	}
+	generated, err := g.createStageTemplate(stageWithDefaults(Stage{Name: stage}), repo.Organization, repo.Name)
+	if err != nil {
+		return false, err
+	}
+	return string(content) == generated, nil
+}
+
+// secretNamespace is where the ArgoCD repository secrets of the deploy keys are kept.
+func (g *Config) secretNamespace() string {
+	if g.Namespace == "" {
+		return argoNamespace
+	}
+	return g.Namespace
+}
+
+// argoSecrets returns the names of the ArgoCD secrets platformer created for deploy keys.
+func (g *Config) argoSecrets() ([]string, error) {
+	clientset, err := helpers.KubernetesClient()
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := clientset.CoreV1().Secrets(g.secretNamespace()).List(context.TODO(), v1.ListOptions{
+		LabelSelector: helpers.ManagedByLabel + "=" + helpers.ManagedBy + "," + helpers.ComponentLabel + "=gitea",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	var names []string
+	for _, secret := range secrets.Items {
+		names = append(names, secret.Name)
+	}
+	return names, nil
+}
+
+func (g *Config) planPruneSecrets() ([]deletion, error) {
+	secrets, err := g.argoSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	declared := map[string]bool{}
+	for _, repo := range g.Repositories {
+		declared[deployKeySecretName(repo)] = true
+	}
+
+	var deletions []deletion
+	for _, secret := range secrets {
+		if declared[secret] {
+			continue
+		}
+		deletions = append(deletions, g.secretDeletion(secret))
+	}
+	return deletions, nil
+}
+
+func (g *Config) secretDeletion(name string) deletion {
+	namespace := g.secretNamespace()
+	return deletion{KindArgoSecret, namespace + "/" + name, func() error {
+		clientset, err := helpers.KubernetesClient()
+		if err != nil {
+			return err
+		}
+		return clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), name, v1.DeleteOptions{})
+	}}
+}
+
+// Prune deletes the Gitea resources and ArgoCD secrets platformer created which are
+// no longer declared. The filter decides which of those deletions are applied.
+func (g *Config) Prune(filter func(kind string, name string) bool) ([]plan.Change, error) {
+	client, err := g.client()
+	if err != nil {
+		return nil, err
+	}
+
+	deletions, err := g.planPrune(client)
+	if err != nil {
+		return nil, err
+	}
+	return g.applyDeletions(deletions, filter)
+}
+
+// applyDeletions deletes in order and stops at the first failure.
+func (g *Config) applyDeletions(deletions []deletion, filter func(kind string, name string) bool) ([]plan.Change, error) {
+	var deleted []plan.Change
+	for _, d := range deletions {
+		if !filter(d.kind, d.name) {
+			continue
+		}
+
+		if g.DryRun {
+			helpers.LogDryRun("DELETE", d.kind+" "+d.name, nil)
+			deleted = append(deleted, plan.Change{Kind: d.kind, Name: d.name, Action: plan.ActionDelete})
+			continue
+		}
+
+		err := d.delete()
+		if err != nil {
+			return deleted, fmt.Errorf("error deleting %s %s: %w", d.kind, d.name, err)
+		}
+		log.Println(fmt.Sprintf("Deleted %s %s", d.kind, d.name))
+		g.state.Delete(d.kind, d.name)
+		if d.kind == KindRepository {
+			g.state.Delete(KindDeployKey, d.name)
+		}
+		deleted = append(deleted, plan.Change{Kind: d.kind, Name: d.name, Action: plan.ActionDelete})
+	}
+	return deleted, nil
+}
+
+func listOrganizations(client *gitea.Client) ([]*gitea.Organization, error) {
+	var all []*gitea.Organization
+	for page := 1; ; page++ {
+		orgs, _, err := client.ListMyOrgs(gitea.ListOrgsOptions{ListOptions: gitea.ListOptions{Page: page, PageSize: listPageSize}})
+		if err != nil {
+			return nil, fmt.Errorf("error listing organizations: %w", err)
+		}
+		all = append(all, orgs...)
+		if len(orgs) < listPageSize {
+			return all, nil
+		}
+	}
+}
+
+func listRepositories(client *gitea.Client, org string) ([]*gitea.Repository, error) {
+	var all []*gitea.Repository
+	for page := 1; ; page++ {
+		repos, _, err := client.ListOrgRepos(org, gitea.ListOrgReposOptions{ListOptions: gitea.ListOptions{Page: page, PageSize: listPageSize}})
+		if err != nil {
+			return nil, fmt.Errorf("error listing repositories of %s: %w", org, err)
+		}
+		all = append(all, repos...)
+		if len(repos) < listPageSize {
+			return all, nil
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package gitea
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/thschue/platformer/pkg/state"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPlanPruneAppSets(t *testing.T) {
+	repo := Repository{Organization: "org", Name: "app", Stages: []Stage{{Name: "dev"}}}
+
+	file := func(g *Config, stage string, sha string, content string) string {
+		if content == "" {
+			generated, err := g.createStageTemplate(stageWithDefaults(Stage{Name: stage}), repo.Organization, repo.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content = generated
+		}
+		return fmt.Sprintf(`{"name": "appset.yaml", "path": "%s/appset.yaml", "sha": "%s", "type": "file", "content": "%s"}`,
+			stage, sha, base64.StdEncoding.EncodeToString([]byte(content)))
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		content  map[string]string
+		recorded map[string]string
+		deleted  []string
+	}{
+		{
+			name:    "generated appset of a removed stage",
+			files:   map[string]string{"prod": "a1"},
+			deleted: []string{"org/app/prod"},
+		},
+		{
+			name:    "hand-written appset",
+			files:   map[string]string{"manual": "b1"},
+			content: map[string]string{"manual": "kind: ApplicationSet\nmetadata:\n  name: manual\n"},
+		},
+		{
+			name:     "recorded appset",
+			files:    map[string]string{"prod": "c1"},
+			content:  map[string]string{"prod": "edited by platformer before"},
+			recorded: map[string]string{"org/app/prod": "c1"},
+			deleted:  []string{"org/app/prod"},
+		},
+		{
+			name:     "appset changed since it was recorded",
+			files:    map[string]string{"prod": "d2"},
+			content:  map[string]string{"prod": "edited by hand"},
+			recorded: map[string]string{"org/app/prod": "d1"},
+		},
+		{
+			name:  "declared stage",
+			files: map[string]string{"dev": "e1"},
+		},
+		{
+			name:    "directory without appset",
+			files:   map[string]string{},
+			content: map[string]string{"docs": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := map[string]string{}
+			g, _ := fakeGitea(t, responses)
+
+			var entries []string
+			for dir := range tt.content {
+				if _, ok := tt.files[dir]; !ok {
+					entries = append(entries, fmt.Sprintf(`{"name": "%s", "path": "%s", "type": "dir"}`, dir, dir))
+				}
+			}
+			for dir, sha := range tt.files {
+				entries = append(entries, fmt.Sprintf(`{"name": "%s", "path": "%s", "type": "dir"}`, dir, dir))
+				responses["GET /repos/org/app/contents/"+dir+"/appset.yaml"] = file(g, dir, sha, tt.content[dir])
+			}
+			responses["GET /repos/org/app/contents/"] = "[" + strings.Join(entries, ",") + "]"
+
+			s := state.New()
+			for name, sha := range tt.recorded {
+				s.Set(state.Resource{Kind: KindAppSet, Name: name, SHA: sha})
+			}
+			g.SetState(s)
+
+			client, err := g.client()
+			if err != nil {
+				t.Fatal(err)
+			}
+			deletions, err := g.planPruneAppSets(client, repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var deleted []string
+			for _, d := range deletions {
+				deleted = append(deleted, d.name)
+			}
+			sort.Strings(deleted)
+			if strings.Join(deleted, ",") != strings.Join(tt.deleted, ",") {
+				t.Errorf("expected deletions %v, got %v", tt.deleted, deleted)
+			}
+		})
+	}
+}
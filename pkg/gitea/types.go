@@ -3,33 +3,43 @@ package gitea
 import (
 	"code.gitea.io/sdk/gitea"
 	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/state"
+	"net/http"
+	"sync"
 )
 
 type Config struct {
-	Url          string              `yaml:"url"`
-	SSHUrl       string              `yaml:"sshUrl"`
-	Credentials  helpers.Credentials `yaml:"credentials"`
-	Orgs         []Organization      `yaml:"orgs"`
-	Repositories []Repository        `yaml:"repositories"`
-	TLSConfig    helpers.TlsConfig   `yaml:"tlsConfig"`
-	Namespace    string              `yaml:"namespace"`
+	Url          string            `yaml:"url"`
+	SSHUrl       string            `yaml:"sshUrl,omitempty"`
+	Credentials  Credentials       `yaml:"credentials"`
+	Orgs         []Organization    `yaml:"orgs,omitempty"`
+	Repositories []Repository      `yaml:"repositories,omitempty"`
+	TLSConfig    helpers.TlsConfig `yaml:"tlsConfig,omitempty"`
+	Namespace    string            `yaml:"namespace,omitempty"`
+	DryRun       bool              `yaml:"-" json:"-" mapstructure:"-"`
+
+	giteaClient   *gitea.Client
+	httpTransport *http.Transport
+	clientMu      sync.Mutex
+	state         *state.State
 }
 
 type Organization struct {
-	Name       string            `yaml:"name"`
-	Visibility gitea.VisibleType `yaml:"visibility"`
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description,omitempty"`
+	Visibility  gitea.VisibleType `yaml:"visibility,omitempty"`
 }
 
 type Repository struct {
 	Name         string  `yaml:"name"`
 	Organization string  `yaml:"organization"`
-	Description  string  `yaml:"description"`
-	Private      bool    `yaml:"private"`
-	Stages       []Stage `yaml:"stages"`
+	Description  string  `yaml:"description,omitempty"`
+	Private      bool    `yaml:"private,omitempty"`
+	Stages       []Stage `yaml:"stages,omitempty"`
 }
 
 type Stage struct {
 	Name        string `yaml:"name"`
-	ArgoProject string `yaml:"argoProject"`
-	ArgoCluster string `yaml:"argoCluster"`
+	ArgoProject string `yaml:"argoProject,omitempty"`
+	ArgoCluster string `yaml:"argoCluster,omitempty"`
 }
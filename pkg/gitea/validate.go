@@ -0,0 +1,69 @@
+package gitea
+
+import (
+	"code.gitea.io/sdk/gitea"
+	"errors"
+	"fmt"
+)
+
+func (g *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	switch g.Credentials.Method {
+	case "", AuthBasic:
+	case AuthToken, AuthOAuth2:
+		if !g.Credentials.Token.IsSet() {
+			invalid("gitea.credentials: token is required for method %s", g.Credentials.Method)
+		}
+	default:
+		invalid("gitea.credentials: unknown method %q", g.Credentials.Method)
+	}
+
+	orgs := map[string]bool{}
+	for i, org := range g.Orgs {
+		switch {
+		case org.Name == "":
+			invalid("gitea.orgs[%d]: name is required", i)
+		case orgs[org.Name]:
+			invalid("gitea.orgs[%d]: duplicate organization %s", i, org.Name)
+		}
+		orgs[org.Name] = true
+
+		switch org.Visibility {
+		case "", gitea.VisibleTypePublic, gitea.VisibleTypeLimited, gitea.VisibleTypePrivate:
+		default:
+			invalid("gitea.orgs[%d]: unknown visibility %q", i, org.Visibility)
+		}
+	}
+
+	repos := map[string]bool{}
+	for i, repo := range g.Repositories {
+		switch {
+		case repo.Name == "":
+			invalid("gitea.repositories[%d]: name is required", i)
+		case repos[repositoryName(repo)]:
+			invalid("gitea.repositories[%d]: duplicate repository %s", i, repositoryName(repo))
+		}
+		repos[repositoryName(repo)] = true
+
+		if !orgs[repo.Organization] {
+			invalid("gitea.repositories[%d]: organization %q is not declared in gitea.orgs", i, repo.Organization)
+		}
+
+		stages := map[string]bool{}
+		for j, stage := range repo.Stages {
+			switch {
+			case stage.Name == "":
+				invalid("gitea.repositories[%d].stages[%d]: name is required", i, j)
+			case stages[stage.Name]:
+				invalid("gitea.repositories[%d].stages[%d]: duplicate stage %s", i, j, stage.Name)
+			}
+			stages[stage.Name] = true
+		}
+	}
+
+	return errors.Join(errs...)
+}
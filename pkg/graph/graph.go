@@ -0,0 +1,235 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/thschue/platformer/pkg/plan"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Node is a single resource which is applied once all of its dependencies succeeded.
+type Node struct {
+	Kind string
+	Name string
+	// DependsOn holds the IDs of the nodes which have to be applied first. Dependencies
+	// which are not part of the graph are treated as satisfied.
+	DependsOn []string
+	// Apply reconciles the resource and reports which action it took.
+	Apply func() (plan.Action, error)
+}
+
+type Result struct {
+	Node    Node
+	Action  plan.Action
+	Err     error
+	Skipped bool
+}
+
+type Graph struct {
+	nodes map[string]Node
+	order []string
+}
+
+func ID(kind string, name string) string {
+	return kind + "/" + name
+}
+
+func (n Node) ID() string {
+	return ID(n.Kind, n.Name)
+}
+
+func New() *Graph {
+	return &Graph{
+		nodes: map[string]Node{},
+	}
+}
+
+func (g *Graph) Add(nodes ...Node) error {
+	for _, node := range nodes {
+		id := node.ID()
+		if _, ok := g.nodes[id]; ok {
+			return fmt.Errorf("duplicate resource %s", id)
+		}
+		g.nodes[id] = node
+		g.order = append(g.order, id)
+	}
+	return nil
+}
+
+// dependencies returns the number of unresolved dependencies of every node and the
+// nodes depending on each node.
+func (g *Graph) dependencies() (map[string]int, map[string][]string) {
+	remaining := map[string]int{}
+	dependents := map[string][]string{}
+	for _, id := range g.order {
+		for _, dep := range g.nodes[id].DependsOn {
+			if _, ok := g.nodes[dep]; !ok {
+				continue
+			}
+			remaining[id]++
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+	return remaining, dependents
+}
+
+func (g *Graph) checkCycles() error {
+	remaining, dependents := g.dependencies()
+
+	var queue []string
+	for _, id := range g.order {
+		if remaining[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	resolved := 0
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		resolved++
+		for _, dependent := range dependents[id] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	if resolved == len(g.order) {
+		return nil
+	}
+
+	var cycle []string
+	for id, count := range remaining {
+		if count > 0 {
+			cycle = append(cycle, id)
+		}
+	}
+	sort.Strings(cycle)
+	return fmt.Errorf("dependency cycle between %s", strings.Join(cycle, ", "))
+}
+
+// Run applies all nodes with at most workers running concurrently. A failed node
+// skips the nodes which transitively depend on it, and with failFast no further nodes
+// are started at all. Results are returned in the order the nodes finished.
+func (g *Graph) Run(ctx context.Context, workers int, failFast bool) ([]Result, error) {
+	err := g.checkCycles()
+	if err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	remaining, dependents := g.dependencies()
+
+	type finished struct {
+		id     string
+		action plan.Action
+		err    error
+	}
+
+	jobs := make(chan string)
+	done := make(chan finished)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				action, err := g.nodes[id].Apply()
+				done <- finished{id, action, err}
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	var queue []string
+	for _, id := range g.order {
+		if remaining[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	var results []Result
+	completed := map[string]bool{}
+
+	var skip func(id string, cause string)
+	skip = func(id string, cause string) {
+		for _, dependent := range dependents[id] {
+			if completed[dependent] {
+				continue
+			}
+			completed[dependent] = true
+			results = append(results, Result{
+				Node:    g.nodes[dependent],
+				Err:     fmt.Errorf("skipped %s because %s failed", dependent, cause),
+				Skipped: true,
+			})
+			skip(dependent, cause)
+		}
+	}
+
+	// stopped returns why no further nodes are started, if at all.
+	var failure error
+	stopped := func() error {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return failure
+	}
+
+	cancelled := ctx.Done()
+	running := 0
+	for len(completed) < len(g.order) {
+		if reason := stopped(); reason != nil && running == 0 {
+			for _, id := range g.order {
+				if !completed[id] {
+					completed[id] = true
+					results = append(results, Result{Node: g.nodes[id], Err: fmt.Errorf("skipped %s: %w", id, reason), Skipped: true})
+				}
+			}
+			break
+		}
+
+		var next chan string
+		var id string
+		if len(queue) > 0 && stopped() == nil {
+			next = jobs
+			id = queue[0]
+		}
+
+		select {
+		case next <- id:
+			queue = queue[1:]
+			running++
+		case f := <-done:
+			running--
+			completed[f.id] = true
+			results = append(results, Result{Node: g.nodes[f.id], Action: f.action, Err: f.err})
+			if f.err != nil {
+				skip(f.id, f.id)
+				if failFast && failure == nil {
+					failure = fmt.Errorf("%s failed", f.id)
+				}
+				continue
+			}
+			for _, dependent := range dependents[f.id] {
+				remaining[dependent]--
+				if remaining[dependent] == 0 && !completed[dependent] {
+					queue = append(queue, dependent)
+				}
+			}
+		case <-cancelled:
+			cancelled = nil
+		}
+	}
+
+	return results, nil
+}
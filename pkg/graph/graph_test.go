@@ -0,0 +1,214 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"github.com/thschue/platformer/pkg/plan"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testNode struct {
+	name      string
+	dependsOn []string
+	fails     bool
+	cancels   bool
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodes     []testNode
+		workers   int
+		failFast  bool
+		cancelled bool
+		status    map[string]string
+		err       string
+	}{
+		{
+			name: "dependencies first",
+			nodes: []testNode{
+				{name: "replication", dependsOn: []string{"test/registry", "test/project"}},
+				{name: "project", dependsOn: []string{"test/registry"}},
+				{name: "registry"},
+			},
+			workers: 4,
+			status:  map[string]string{"replication": "applied", "project": "applied", "registry": "applied"},
+		},
+		{
+			name: "missing dependency is satisfied",
+			nodes: []testNode{
+				{name: "project", dependsOn: []string{"test/undeclared"}},
+			},
+			workers: 1,
+			status:  map[string]string{"project": "applied"},
+		},
+		{
+			name: "failure skips dependents",
+			nodes: []testNode{
+				{name: "registry", fails: true},
+				{name: "project", dependsOn: []string{"test/registry"}},
+				{name: "replication", dependsOn: []string{"test/project"}},
+				{name: "robot"},
+			},
+			workers: 1,
+			status:  map[string]string{"registry": "failed", "project": "skipped", "replication": "skipped", "robot": "applied"},
+		},
+		{
+			name: "fail fast",
+			nodes: []testNode{
+				{name: "registry", fails: true},
+				{name: "robot"},
+				{name: "org"},
+			},
+			workers:  1,
+			failFast: true,
+			status:   map[string]string{"registry": "failed", "robot": "skipped", "org": "skipped"},
+		},
+		{
+			name: "cancelled before the run",
+			nodes: []testNode{
+				{name: "registry"},
+				{name: "robot"},
+			},
+			workers:   2,
+			cancelled: true,
+			status:    map[string]string{"registry": "skipped", "robot": "skipped"},
+		},
+		{
+			name: "cancelled during the run",
+			nodes: []testNode{
+				{name: "registry", cancels: true},
+				{name: "project", dependsOn: []string{"test/registry"}},
+			},
+			workers: 1,
+			status:  map[string]string{"registry": "applied", "project": "skipped"},
+		},
+		{
+			name: "cycle",
+			nodes: []testNode{
+				{name: "a", dependsOn: []string{"test/b"}},
+				{name: "b", dependsOn: []string{"test/a"}},
+				{name: "c"},
+			},
+			workers: 1,
+			err:     "dependency cycle between test/a, test/b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			var mu sync.Mutex
+			applied := map[string]bool{}
+			g := New()
+			for _, n := range tt.nodes {
+				n := n
+				err := g.Add(Node{Kind: "test", Name: n.name, DependsOn: n.dependsOn, Apply: func() (plan.Action, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					for _, dep := range n.dependsOn {
+						if _, declared := g.nodes[dep]; declared && !applied[dep] {
+							t.Errorf("%s applied before its dependency %s", n.name, dep)
+						}
+					}
+					if n.cancels {
+						cancel()
+					}
+					if n.fails {
+						return "", errors.New("failed")
+					}
+					applied[ID("test", n.name)] = true
+					return plan.ActionCreate, nil
+				}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			results, err := g.Run(ctx, tt.workers, tt.failFast)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(results) != len(tt.nodes) {
+				t.Fatalf("expected %d results, got %+v", len(tt.nodes), results)
+			}
+			for _, result := range results {
+				status := "applied"
+				switch {
+				case result.Skipped:
+					status = "skipped"
+				case result.Err != nil:
+					status = "failed"
+				}
+				if status != tt.status[result.Node.Name] {
+					t.Errorf("expected %s to be %s, got %s (%v)", result.Node.Name, tt.status[result.Node.Name], status, result.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestAddDuplicate(t *testing.T) {
+	g := New()
+	err := g.Add(Node{Kind: "harbor/project", Name: "library"}, Node{Kind: "harbor/project", Name: "library"})
+	if err == nil || !strings.Contains(err.Error(), "duplicate resource harbor/project/library") {
+		t.Errorf("expected a duplicate error, got %v", err)
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	const workers = 2
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	release := make(chan struct{})
+
+	g := New()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		g.Add(Node{Kind: "test", Name: name, Apply: func() (plan.Action, error) {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+
+			<-release
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return plan.ActionNoOp, nil
+		}})
+	}
+	go func() {
+		for i := 0; i < 5; i++ {
+			release <- struct{}{}
+		}
+	}()
+
+	results, err := g.Run(context.Background(), workers, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 5 {
+		t.Errorf("expected 5 results, got %d", len(results))
+	}
+	if peak > workers {
+		t.Errorf("expected at most %d nodes at once, got %d", workers, peak)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"github.com/thschue/platformer/pkg/helpers"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const basePath = "/api/v2.0"
+
+const pageSize = 100
+
+const requestTimeout = 60 * time.Second
+
+// Client is a typed client for the Harbor v2.0 REST API.
+type Client struct {
+	url      string
+	username string
+	password string
+	http     *http.Client
+
+	// DryRun skips all writes and only logs the payload they would have sent.
+	DryRun bool
+}
+
+func New(url string, username string, password string, insecureSkipVerify bool) *Client {
+	return &Client{
+		url:      strings.TrimSuffix(url, "/"),
+		username: username,
+		password: password,
+		http: &http.Client{
+			Timeout: requestTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: insecureSkipVerify,
+				},
+			},
+		},
+	}
+}
+
+// do sends a request to the API and decodes the response into out. Responses with a
+// status code outside of 2xx are returned as *Error.
+func (c *Client) do(method string, endpoint string, body interface{}, out interface{}) (*http.Response, error) {
+	if c.DryRun && method != http.MethodGet {
+		helpers.LogDryRun(method, c.url+basePath+endpoint, body)
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.url+basePath+endpoint, reader)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth(c.username, c.password)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error querying api: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, newError(method, endpoint, resp)
+	}
+
+	if out != nil {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil && err != io.EOF {
+			return resp, fmt.Errorf("error decoding response of %s %s: %w", method, endpoint, err)
+		}
+	}
+	return resp, nil
+}
+
+func (c *Client) get(endpoint string, out interface{}) error {
+	_, err := c.do(http.MethodGet, endpoint, nil, out)
+	return err
+}
+
+// create posts a new resource and returns the id Harbor reports in the Location header.
+func (c *Client) create(endpoint string, body interface{}, out interface{}) (int64, error) {
+	resp, err := c.do(http.MethodPost, endpoint, body, out)
+	if err != nil {
+		return 0, err
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(path.Base(location), 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+	return id, nil
+}
+
+func (c *Client) update(endpoint string, body interface{}) error {
+	_, err := c.do(http.MethodPut, endpoint, body, nil)
+	return err
+}
+
+func (c *Client) delete(endpoint string) error {
+	_, err := c.do(http.MethodDelete, endpoint, nil, nil)
+	return err
+}
+
+// list fetches all pages of a collection.
+func list[T any](c *Client, endpoint string) ([]T, error) {
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+
+	var all []T
+	for page := 1; ; page++ {
+		var items []T
+		err := c.get(fmt.Sprintf("%s%spage=%d&page_size=%d", endpoint, separator, page, pageSize), &items)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if len(items) < pageSize {
+			return all, nil
+		}
+	}
+}
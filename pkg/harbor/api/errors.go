@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Error is returned for every response with a status code outside of 2xx.
+type Error struct {
+	Method     string
+	Endpoint   string
+	StatusCode int
+	Errors     []ErrorDetail
+}
+
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newError(method string, endpoint string, resp *http.Response) *Error {
+	apiErr := &Error{
+		Method:     method,
+		Endpoint:   endpoint,
+		StatusCode: resp.StatusCode,
+	}
+
+	var body struct {
+		Errors []ErrorDetail `json:"errors"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(data, &body) == nil {
+		apiErr.Errors = body.Errors
+	}
+	return apiErr
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("harbor: %s %s returned %d %s", e.Method, e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+
+	var details []string
+	for _, detail := range e.Errors {
+		details = append(details, detail.Code+": "+detail.Message)
+	}
+	if len(details) > 0 {
+		msg += " (" + strings.Join(details, "; ") + ")"
+	}
+	return msg
+}
+
+func hasStatus(err error, statusCode int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+)
+
+func (c *Client) ListImmutableRules(project string) ([]ImmutableRule, error) {
+	return list[ImmutableRule](c, "/projects/"+url.PathEscape(project)+"/immutabletagrules")
+}
+
+func (c *Client) CreateImmutableRule(project string, rule ImmutableRule) (int64, error) {
+	return c.create("/projects/"+url.PathEscape(project)+"/immutabletagrules", rule, nil)
+}
+
+func (c *Client) UpdateImmutableRule(project string, id int64, rule ImmutableRule) error {
+	return c.update(fmt.Sprintf("/projects/%s/immutabletagrules/%d", url.PathEscape(project), id), rule)
+}
+
+func (c *Client) DeleteImmutableRule(project string, id int64) error {
+	return c.delete(fmt.Sprintf("/projects/%s/immutabletagrules/%d", url.PathEscape(project), id))
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+)
+
+func (c *Client) ListProjectMembers(project string) ([]ProjectMember, error) {
+	return list[ProjectMember](c, "/projects/"+url.PathEscape(project)+"/members")
+}
+
+func (c *Client) CreateProjectMember(project string, member ProjectMemberReq) (int64, error) {
+	return c.create("/projects/"+url.PathEscape(project)+"/members", member, nil)
+}
+
+func (c *Client) UpdateProjectMember(project string, id int64, role RoleRequest) error {
+	return c.update(fmt.Sprintf("/projects/%s/members/%d", url.PathEscape(project), id), role)
+}
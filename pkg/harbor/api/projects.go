@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+)
+
+func (c *Client) GetProject(name string) (*Project, error) {
+	var project Project
+	err := c.get("/projects/"+url.PathEscape(name), &project)
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (c *Client) ListProjects() ([]Project, error) {
+	return list[Project](c, "/projects")
+}
+
+func (c *Client) CreateProject(project ProjectReq) (int64, error) {
+	return c.create("/projects", project, nil)
+}
+
+func (c *Client) UpdateProject(name string, project ProjectReq) error {
+	return c.update("/projects/"+url.PathEscape(name), project)
+}
+
+func (c *Client) DeleteProject(name string) error {
+	return c.delete("/projects/" + url.PathEscape(name))
+}
+
+func (c *Client) ListProjectLabels(projectID int64, name string) ([]Label, error) {
+	return list[Label](c, fmt.Sprintf("/labels?scope=p&project_id=%d&name=%s", projectID, url.QueryEscape(name)))
+}
+
+func (c *Client) CreateLabel(label Label) (int64, error) {
+	return c.create("/labels", label, nil)
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"fmt"
+)
+
+// GetProjectQuota returns the quota of a project, which Harbor creates with the project.
+func (c *Client) GetProjectQuota(projectID int64) (*Quota, error) {
+	quotas, err := list[Quota](c, fmt.Sprintf("/quotas?reference=project&reference_id=%d", projectID))
+	if err != nil {
+		return nil, err
+	}
+	if len(quotas) == 0 {
+		return nil, fmt.Errorf("project %d has no quota", projectID)
+	}
+	return &quotas[0], nil
+}
+
+func (c *Client) UpdateQuota(id int64, quota QuotaUpdate) error {
+	return c.update(fmt.Sprintf("/quotas/%d", id), quota)
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"fmt"
+)
+
+func (c *Client) ListRegistries() ([]Registry, error) {
+	return list[Registry](c, "/registries")
+}
+
+func (c *Client) CreateRegistry(registry Registry) (int64, error) {
+	return c.create("/registries", registry, nil)
+}
+
+func (c *Client) UpdateRegistry(id int64, registry RegistryUpdate) error {
+	return c.update(fmt.Sprintf("/registries/%d", id), registry)
+}
+
+func (c *Client) DeleteRegistry(id int64) error {
+	return c.delete(fmt.Sprintf("/registries/%d", id))
+}
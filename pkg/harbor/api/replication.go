@@ -0,0 +1,25 @@
+package api
+
+import (
+	"fmt"
+)
+
+func (c *Client) ListReplicationPolicies() ([]ReplicationPolicy, error) {
+	return list[ReplicationPolicy](c, "/replication/policies")
+}
+
+func (c *Client) CreateReplicationPolicy(policy ReplicationPolicy) (int64, error) {
+	return c.create("/replication/policies", policy, nil)
+}
+
+func (c *Client) UpdateReplicationPolicy(id int64, policy ReplicationPolicy) error {
+	return c.update(fmt.Sprintf("/replication/policies/%d", id), policy)
+}
+
+func (c *Client) DeleteReplicationPolicy(id int64) error {
+	return c.delete(fmt.Sprintf("/replication/policies/%d", id))
+}
+
+func (c *Client) StartReplicationExecution(policyID int64) (int64, error) {
+	return c.create("/replication/executions", StartReplicationExecution{PolicyID: policyID}, nil)
+}
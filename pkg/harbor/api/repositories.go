@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+)
+
+func (c *Client) ListRepositories(project string) ([]Repository, error) {
+	return list[Repository](c, "/projects/"+url.PathEscape(project)+"/repositories")
+}
+
+// DeleteRepository deletes a repository with all of its artifacts. The name is given
+// without the project, and Harbor expects slashes in it to be encoded twice.
+func (c *Client) DeleteRepository(project string, name string) error {
+	name = url.PathEscape(strings.ReplaceAll(name, "/", "%2F"))
+	return c.delete("/projects/" + url.PathEscape(project) + "/repositories/" + name)
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"fmt"
+)
+
+func (c *Client) GetRetention(id int64) (*RetentionPolicy, error) {
+	var policy RetentionPolicy
+	err := c.get(fmt.Sprintf("/retentions/%d", id), &policy)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// CreateRetention creates a policy, which Harbor links to the project of its scope
+// through the retention_id metadata.
+func (c *Client) CreateRetention(policy RetentionPolicy) (int64, error) {
+	return c.create("/retentions", policy, nil)
+}
+
+func (c *Client) UpdateRetention(id int64, policy RetentionPolicy) error {
+	return c.update(fmt.Sprintf("/retentions/%d", id), policy)
+}
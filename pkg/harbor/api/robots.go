@@ -0,0 +1,37 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (c *Client) ListRobots() ([]Robot, error) {
+	return list[Robot](c, "/robots")
+}
+
+func (c *Client) CreateRobot(robot RobotCreate) (*RobotCreated, error) {
+	var created RobotCreated
+	_, err := c.create("/robots", robot, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) UpdateRobot(id int64, robot Robot) error {
+	return c.update(fmt.Sprintf("/robots/%d", id), robot)
+}
+
+func (c *Client) DeleteRobot(id int64) error {
+	return c.delete(fmt.Sprintf("/robots/%d", id))
+}
+
+// RefreshRobotSecret replaces the secret of a robot with a random one and returns it.
+func (c *Client) RefreshRobotSecret(id int64) (string, error) {
+	var sec RobotSec
+	_, err := c.do(http.MethodPatch, fmt.Sprintf("/robots/%d", id), RobotSec{}, &sec)
+	if err != nil {
+		return "", err
+	}
+	return sec.Secret, nil
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+)
+
+func (c *Client) Ping() error {
+	_, err := c.do(http.MethodGet, "/ping", nil, nil)
+	return err
+}
+
+func (c *Client) Health() (*Health, error) {
+	var health Health
+	err := c.get("/health", &health)
+	if err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+func (c *Client) GetConfigurations() (map[string]ConfigurationValue, error) {
+	var configurations map[string]ConfigurationValue
+	err := c.get("/configurations", &configurations)
+	if err != nil {
+		return nil, err
+	}
+	return configurations, nil
+}
+
+func (c *Client) UpdateConfigurations(configurations map[string]interface{}) error {
+	return c.update("/configurations", configurations)
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"time"
+)
+
+type Health struct {
+	Status     string            `json:"status"`
+	Components []ComponentHealth `json:"components"`
+}
+
+type ComponentHealth struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ConfigurationValue struct {
+	Value    interface{} `json:"value"`
+	Editable bool        `json:"editable"`
+}
+
+type Project struct {
+	ProjectID    int64             `json:"project_id"`
+	Name         string            `json:"name"`
+	RepoCount    int64             `json:"repo_count"`
+	RegistryID   int64             `json:"registry_id"`
+	Metadata     map[string]string `json:"metadata"`
+	CVEAllowlist *CVEAllowlist     `json:"cve_allowlist,omitempty"`
+}
+
+type ProjectReq struct {
+	ProjectName  string            `json:"project_name"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	StorageLimit *int64            `json:"storage_limit,omitempty"`
+	CVEAllowlist *CVEAllowlist     `json:"cve_allowlist,omitempty"`
+	// RegistryID makes the project a proxy cache of the registry.
+	RegistryID *int64 `json:"registry_id,omitempty"`
+}
+
+type CVEAllowlist struct {
+	ID        int64              `json:"id,omitempty"`
+	ProjectID int64              `json:"project_id,omitempty"`
+	ExpiresAt *int64             `json:"expires_at"`
+	Items     []CVEAllowlistItem `json:"items"`
+}
+
+type CVEAllowlistItem struct {
+	CVEID string `json:"cve_id"`
+}
+
+type Quota struct {
+	ID   int64                  `json:"id"`
+	Hard map[string]interface{} `json:"hard"`
+	Used map[string]interface{} `json:"used"`
+}
+
+type QuotaUpdate struct {
+	Hard map[string]int64 `json:"hard"`
+}
+
+type ProjectMember struct {
+	ID         int64  `json:"id"`
+	ProjectID  int64  `json:"project_id"`
+	EntityName string `json:"entity_name"`
+	EntityType string `json:"entity_type"`
+	EntityID   int64  `json:"entity_id"`
+	RoleID     int64  `json:"role_id"`
+	RoleName   string `json:"role_name"`
+}
+
+type ProjectMemberReq struct {
+	RoleID      int64            `json:"role_id"`
+	MemberUser  *UserEntity      `json:"member_user,omitempty"`
+	MemberGroup *UserGroupEntity `json:"member_group,omitempty"`
+}
+
+type UserEntity struct {
+	Username string `json:"username"`
+}
+
+type UserGroupEntity struct {
+	GroupName string `json:"group_name"`
+}
+
+type RoleRequest struct {
+	RoleID int64 `json:"role_id"`
+}
+
+type Repository struct {
+	ID            int64  `json:"id"`
+	ProjectID     int64  `json:"project_id"`
+	Name          string `json:"name"`
+	ArtifactCount int64  `json:"artifact_count"`
+}
+
+type Label struct {
+	ID          int64  `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	Scope       string `json:"scope"`
+	ProjectID   int64  `json:"project_id"`
+}
+
+type Registry struct {
+	ID          int64               `json:"id,omitempty"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	URL         string              `json:"url"`
+	Type        string              `json:"type"`
+	Insecure    bool                `json:"insecure"`
+	Credential  *RegistryCredential `json:"credential,omitempty"`
+}
+
+type RegistryCredential struct {
+	Type         string `json:"type,omitempty"`
+	AccessKey    string `json:"access_key"`
+	AccessSecret string `json:"access_secret,omitempty"`
+}
+
+type RegistryUpdate struct {
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	URL            string `json:"url"`
+	CredentialType string `json:"credential_type,omitempty"`
+	AccessKey      string `json:"access_key"`
+	AccessSecret   string `json:"access_secret,omitempty"`
+	Insecure       bool   `json:"insecure"`
+}
+
+type RegistryRef struct {
+	ID int64 `json:"id"`
+}
+
+type ReplicationPolicy struct {
+	ID            int64               `json:"id,omitempty"`
+	Name          string              `json:"name"`
+	Description   string              `json:"description"`
+	SrcRegistry   *RegistryRef        `json:"src_registry,omitempty"`
+	DestRegistry  *RegistryRef        `json:"dest_registry,omitempty"`
+	DestNamespace string              `json:"dest_namespace"`
+	Filters       []ReplicationFilter `json:"filters"`
+	Trigger       *ReplicationTrigger `json:"trigger,omitempty"`
+	Override      bool                `json:"override"`
+	Enabled       bool                `json:"enabled"`
+}
+
+type ReplicationFilter struct {
+	Type       string      `json:"type"`
+	Value      interface{} `json:"value"`
+	Decoration string      `json:"decoration,omitempty"`
+}
+
+type ReplicationTrigger struct {
+	Type            string           `json:"type"`
+	TriggerSettings *TriggerSettings `json:"trigger_settings,omitempty"`
+}
+
+type TriggerSettings struct {
+	Cron string `json:"cron"`
+}
+
+type StartReplicationExecution struct {
+	PolicyID int64 `json:"policy_id"`
+}
+
+type Robot struct {
+	ID          int64             `json:"id,omitempty"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Level       string            `json:"level"`
+	Duration    int64             `json:"duration"`
+	Disable     bool              `json:"disable"`
+	Editable    bool              `json:"editable"`
+	ExpiresAt   int64             `json:"expires_at"`
+	Permissions []RobotPermission `json:"permissions"`
+	// CreationTime is the start of the duration.
+	CreationTime time.Time `json:"creation_time"`
+}
+
+type RobotSec struct {
+	Secret string `json:"secret"`
+}
+
+type RobotCreate struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Level       string            `json:"level"`
+	Duration    int64             `json:"duration"`
+	Disable     bool              `json:"disable"`
+	Permissions []RobotPermission `json:"permissions"`
+}
+
+type RobotCreated struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Secret    string `json:"secret"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+type RobotPermission struct {
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace"`
+	Access    []Access `json:"access"`
+}
+
+type Access struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	Effect   string `json:"effect,omitempty"`
+}
+
+type RetentionPolicy struct {
+	ID        int64             `json:"id,omitempty"`
+	Algorithm string            `json:"algorithm"`
+	Rules     []RetentionRule   `json:"rules"`
+	Trigger   *RetentionTrigger `json:"trigger"`
+	Scope     *RetentionScope   `json:"scope"`
+}
+
+type RetentionRule struct {
+	ID             int64                          `json:"id,omitempty"`
+	Priority       int                            `json:"priority,omitempty"`
+	Disabled       bool                           `json:"disabled"`
+	Action         string                         `json:"action"`
+	Template       string                         `json:"template"`
+	Params         map[string]interface{}         `json:"params"`
+	TagSelectors   []RetentionSelector            `json:"tag_selectors"`
+	ScopeSelectors map[string][]RetentionSelector `json:"scope_selectors"`
+}
+
+type RetentionSelector struct {
+	Kind       string `json:"kind"`
+	Decoration string `json:"decoration"`
+	Pattern    string `json:"pattern"`
+	Extras     string `json:"extras,omitempty"`
+}
+
+type RetentionTrigger struct {
+	Kind     string                 `json:"kind"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+type RetentionScope struct {
+	Level string `json:"level"`
+	Ref   int64  `json:"ref"`
+}
+
+// ImmutableRule shares the template and selectors of a retention rule.
+type ImmutableRule struct {
+	ID             int64                          `json:"id,omitempty"`
+	Priority       int                            `json:"priority,omitempty"`
+	Disabled       bool                           `json:"disabled"`
+	Action         string                         `json:"action"`
+	Template       string                         `json:"template"`
+	Params         map[string]interface{}         `json:"params,omitempty"`
+	TagSelectors   []RetentionSelector            `json:"tag_selectors"`
+	ScopeSelectors map[string][]RetentionSelector `json:"scope_selectors"`
+}
+
+type WebhookPolicy struct {
+	ID          int64           `json:"id,omitempty"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	ProjectID   int64           `json:"project_id,omitempty"`
+	Targets     []WebhookTarget `json:"targets"`
+	EventTypes  []string        `json:"event_types"`
+	Enabled     bool            `json:"enabled"`
+}
+
+type WebhookTarget struct {
+	Type           string `json:"type"`
+	Address        string `json:"address"`
+	AuthHeader     string `json:"auth_header,omitempty"`
+	SkipCertVerify bool   `json:"skip_cert_verify"`
+	PayloadFormat  string `json:"payload_format,omitempty"`
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+)
+
+func (c *Client) ListWebhookPolicies(project string) ([]WebhookPolicy, error) {
+	return list[WebhookPolicy](c, "/projects/"+url.PathEscape(project)+"/webhook/policies")
+}
+
+func (c *Client) CreateWebhookPolicy(project string, policy WebhookPolicy) (int64, error) {
+	return c.create("/projects/"+url.PathEscape(project)+"/webhook/policies", policy, nil)
+}
+
+func (c *Client) UpdateWebhookPolicy(project string, id int64, policy WebhookPolicy) error {
+	return c.update(fmt.Sprintf("/projects/%s/webhook/policies/%d", url.PathEscape(project), id), policy)
+}
+
+func (c *Client) DeleteWebhookPolicy(project string, id int64) error {
+	return c.delete(fmt.Sprintf("/projects/%s/webhook/policies/%d", url.PathEscape(project), id))
+}
@@ -3,33 +3,30 @@ package harbor
 import (
 	"context"
 	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
 	"github.com/thschue/platformer/pkg/helpers"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 	"log"
 	"strings"
 )
 
-func (h *Config) createKubernetesSecretForArgoCD(namespace string, account RobotAccount, secretName string) error {
-	config, err := helpers.BuildKubeConfig()
-	if err != nil {
-		return fmt.Errorf("failed to build kubernetes config: %w", err)
-	}
+const argoNamespace = "argocd"
 
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("failed to create kubernetes clientset: %w", err)
-	}
+func robotSecretName(account RobotAccount) string {
+	return "helm-" + account.Name
+}
 
+func (h *Config) argoSecret(account RobotAccount, secretName string) *corev1.Secret {
 	cleanUrl := strings.ReplaceAll(h.Url, "https://", "")
-	secret := &corev1.Secret{
+	labels := helpers.ManagedLabels("harbor")
+	labels["argocd.argoproj.io/secret-type"] = "repository"
+
+	return &corev1.Secret{
 		ObjectMeta: v1.ObjectMeta{
-			Name: secretName,
-			Labels: map[string]string{
-				"argocd.argoproj.io/secret-type": "repository",
-			},
+			Name:   secretName,
+			Labels: labels,
 		},
 		Data: map[string][]byte{
 			"enableOCI": []byte("true"),
@@ -41,16 +38,97 @@ func (h *Config) createKubernetesSecretForArgoCD(namespace string, account Robot
 			"insecure":  []byte("true"),
 		},
 	}
+}
+
+func (h *Config) createKubernetesSecretForArgoCD(namespace string, account RobotAccount, secretName string) error {
+	secret := h.argoSecret(account, secretName)
 
 	if namespace == "" {
-		namespace = "argocd"
+		namespace = argoNamespace
+	}
+
+	if h.DryRun {
+		helpers.LogDryRun("CREATE", "secret "+namespace+"/"+secretName, helpers.SecretPayload(secret))
+		return nil
+	}
+
+	clientset, err := helpers.KubernetesClient()
+	if err != nil {
+		return err
 	}
 
 	_, err = clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, v1.CreateOptions{})
-	if err != nil && !errors.IsAlreadyExists(err) {
+	if err != nil {
 		return fmt.Errorf("failed to create secret: %w", err)
 	}
 
 	log.Printf("Secret %s created successfully in namespace %s\n", secretName, namespace)
 	return nil
 }
+
+// updateKubernetesSecretForArgoCD replaces the ArgoCD secret of a robot, or creates it
+// if it is missing.
+func (h *Config) updateKubernetesSecretForArgoCD(namespace string, account RobotAccount, secretName string) error {
+	if namespace == "" {
+		namespace = argoNamespace
+	}
+
+	if h.DryRun {
+		helpers.LogDryRun("UPDATE", "secret "+namespace+"/"+secretName, helpers.SecretPayload(h.argoSecret(account, secretName)))
+		return nil
+	}
+
+	clientset, err := helpers.KubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), secretName, v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return h.createKubernetesSecretForArgoCD(namespace, account, secretName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	desired := h.argoSecret(account, secretName)
+	secret.Labels = desired.Labels
+	secret.Data = desired.Data
+
+	_, err = clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, v1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	log.Printf("Secret %s updated in namespace %s\n", secretName, namespace)
+	return nil
+}
+
+// argoSecretStatus reports whether the ArgoCD secret of a robot is missing, incorrect
+// because it lacks a password or points to another robot or Harbor, or ok. Whether the
+// password is still valid cannot be checked, as Harbor never returns it.
+func (h *Config) argoSecretStatus(robot api.Robot, secretName string) (string, error) {
+	clientset, err := helpers.KubernetesClient()
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := clientset.CoreV1().Secrets(argoNamespace).Get(context.TODO(), secretName, v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "missing", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", secretName, err)
+	}
+
+	desired := h.argoSecret(RobotAccount{Name: robot.Name}, secretName)
+	for _, key := range []string{"url", "username", "type"} {
+		if string(secret.Data[key]) != string(desired.Data[key]) {
+			return "incorrect", nil
+		}
+	}
+	if len(secret.Data["password"]) == 0 || secret.Labels["argocd.argoproj.io/secret-type"] != "repository" {
+		return "incorrect", nil
+	}
+	return "ok", nil
+}
@@ -1,13 +1,30 @@
 package harbor
 
-import "fmt"
+import (
+	"fmt"
+	"github.com/thschue/platformer/pkg/plan"
+	"log"
+)
 
-func (h *Config) CreateConfiguration(config map[string]interface{}) error {
-	_, _, err := h.queryApi("PUT", h.Url+configurationApi, config)
+func (h *Config) CreateConfiguration(key string, value interface{}) (plan.Action, error) {
+	live, err := h.client().GetConfigurations()
 	if err != nil {
-		fmt.Println("Error creating configuration")
+		return "", fmt.Errorf("error getting configuration: %w", err)
 	}
 
-	return nil
+	change := planConfigurationValue(live, key, value)
+	if change.Action == plan.ActionNoOp {
+		log.Println(fmt.Sprintf("Configuration %s is up to date", key))
+		return change.Action, nil
+	}
+
+	err = h.client().UpdateConfigurations(map[string]interface{}{
+		key: value,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error updating configuration: %w", err)
+	}
 
+	log.Println(fmt.Sprintf("Configuration %s updated", key))
+	return change.Action, nil
 }
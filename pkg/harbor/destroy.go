@@ -0,0 +1,145 @@
+package harbor
+
+import (
+	"context"
+	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/plan"
+	"slices"
+	"strings"
+)
+
+const KindRepository = "harbor/repository"
+
+// planDestroy lists the declared Harbor resources which exist, in the order they have
+// to be deleted. Projects holding artifacts are refused unless force is set, in which
+// case their repositories are deleted first.
+func (h *Config) planDestroy(force bool) ([]deletion, error) {
+	var deletions []deletion
+
+	policies, err := h.listReplicationPolicies()
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range h.Replications {
+		name := replicationRuleName(rule)
+		for _, policy := range policies {
+			if policy.Name != name {
+				continue
+			}
+			id := policy.ID
+			deletions = append(deletions, deletion{kind: KindReplication, name: name, id: id, delete: func() error {
+				return h.client().DeleteReplicationPolicy(id)
+			}})
+		}
+	}
+
+	robots, err := h.listRobots()
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := h.argoSecrets()
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range h.RobotAccounts {
+		for _, robot := range robots {
+			if robot.Name != robotName(account) {
+				continue
+			}
+			id := robot.ID
+			deletions = append(deletions, deletion{kind: KindRobotAccount, name: account.Name, id: id, delete: func() error {
+				return h.client().DeleteRobot(id)
+			}})
+		}
+
+		if slices.Contains(secrets, robotSecretName(account)) {
+			name := argoNamespace + "/" + robotSecretName(account)
+			deletions = append(deletions, deletion{kind: KindArgoSecret, name: name, delete: func() error {
+				return h.deleteKubernetesSecret(name)
+			}})
+		}
+	}
+
+	var refused []string
+	for _, project := range h.Projects {
+		live, err := h.client().GetProject(project.Name)
+		if api.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting project %s: %w", project.Name, err)
+		}
+
+		var repositories []api.Repository
+		if live.RepoCount > 0 {
+			repositories, err = h.client().ListRepositories(project.Name)
+			if err != nil {
+				return nil, fmt.Errorf("error getting repositories of %s: %w", project.Name, err)
+			}
+		}
+
+		var artifacts int64
+		for _, repository := range repositories {
+			artifacts += repository.ArtifactCount
+		}
+		if artifacts > 0 && !force {
+			refused = append(refused, fmt.Sprintf("%s (%d artifacts)", project.Name, artifacts))
+			continue
+		}
+
+		// Harbor only deletes empty projects.
+		for _, repository := range repositories {
+			projectName, repositoryName := project.Name, strings.TrimPrefix(repository.Name, project.Name+"/")
+			deletions = append(deletions, deletion{kind: KindRepository, name: repository.Name, delete: func() error {
+				return h.client().DeleteRepository(projectName, repositoryName)
+			}})
+		}
+
+		name := project.Name
+		deletions = append(deletions, deletion{kind: KindProject, name: name, delete: func() error {
+			return h.client().DeleteProject(name)
+		}})
+	}
+	if len(refused) > 0 {
+		return nil, fmt.Errorf("refusing to delete projects which contain artifacts, use --force to delete them anyway: %s", strings.Join(refused, ", "))
+	}
+
+	// Registries go last, as proxy cache projects refer to them.
+	registries, err := h.listRegistries()
+	if err != nil {
+		return nil, err
+	}
+	for _, registry := range h.Registries {
+		for _, live := range registries {
+			if live.Name != registry.Name {
+				continue
+			}
+			id := live.ID
+			deletions = append(deletions, deletion{kind: KindRegistry, name: registry.Name, id: id, delete: func() error {
+				return h.client().DeleteRegistry(id)
+			}})
+		}
+	}
+
+	return deletions, nil
+}
+
+// PlanDestroy lists the deletions Destroy would apply.
+func (h *Config) PlanDestroy(force bool) ([]plan.Change, error) {
+	deletions, err := h.planDestroy(force)
+	if err != nil {
+		return nil, err
+	}
+	return deletionChanges(deletions), nil
+}
+
+// Destroy deletes every declared Harbor resource and the ArgoCD secrets of the robots.
+// The configuration values are left as they are.
+func (h *Config) Destroy(ctx context.Context, force bool) ([]plan.Change, error) {
+	deletions, err := h.planDestroy(force)
+	if err != nil {
+		return nil, err
+	}
+	return h.applyDeletions(deletions, func(string, string) bool { return ctx.Err() == nil })
+}
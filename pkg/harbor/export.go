@@ -0,0 +1,225 @@
+package harbor
+
+import (
+	"errors"
+	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/state"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Export replaces the declared resources with the ones found in Harbor, so that the
+// config describes the live instance. Resources platformer cannot express are skipped
+// with a warning, and registry secrets, which Harbor never returns, are left empty.
+func (h *Config) Export() error {
+	configurations, err := h.client().GetConfigurations()
+	if err != nil {
+		return fmt.Errorf("error getting configuration: %w", err)
+	}
+	h.Configuration = map[string]interface{}{}
+	for key, value := range configurations {
+		if value.Editable {
+			h.Configuration[key] = exportConfigurationValue(value.Value)
+		}
+	}
+
+	registries, err := h.listRegistries()
+	if err != nil {
+		return err
+	}
+
+	projects, err := h.client().ListProjects()
+	if err != nil {
+		return fmt.Errorf("error getting projects: %w", err)
+	}
+	h.Projects = nil
+	for _, project := range projects {
+		h.Projects = append(h.Projects, exportProject(project, registries))
+	}
+
+	h.Registries = nil
+	for _, registry := range registries {
+		exported := Registry{
+			Name:        registry.Name,
+			Description: helpers.UnmarkManaged(registry.Description),
+			Url:         registry.URL,
+			Type:        registry.Type,
+		}
+		if registry.Credential != nil && registry.Credential.AccessKey != "" {
+			exported.Credentials.AccessKey = registry.Credential.AccessKey
+			log.Println(fmt.Sprintf("Registry %s: the access secret cannot be exported, set credentials.accessSecret", registry.Name))
+		}
+		h.Registries = append(h.Registries, exported)
+	}
+
+	policies, err := h.listReplicationPolicies()
+	if err != nil {
+		return err
+	}
+	h.Replications = nil
+	for _, policy := range policies {
+		rule, err := exportReplicationPolicy(policy, registries)
+		if err != nil {
+			log.Println(fmt.Sprintf("Skipping replication rule %s: %v", policy.Name, err))
+			continue
+		}
+		h.Replications = append(h.Replications, rule)
+	}
+
+	robots, err := h.listRobots()
+	if err != nil {
+		return err
+	}
+	h.RobotAccounts = nil
+	for _, robot := range robots {
+		recorded, _ := h.state.Get(KindRobotAccount, exportRobotName(robot))
+		account, err := exportRobot(robot, recorded)
+		if err != nil {
+			log.Println(fmt.Sprintf("Skipping robot %s: %v", robot.Name, err))
+			continue
+		}
+		h.RobotAccounts = append(h.RobotAccounts, account)
+	}
+
+	sort.Slice(h.Projects, func(i, j int) bool { return h.Projects[i].Name < h.Projects[j].Name })
+	sort.Slice(h.Registries, func(i, j int) bool { return h.Registries[i].Name < h.Registries[j].Name })
+	sort.Slice(h.Replications, func(i, j int) bool { return h.Replications[i].Repository < h.Replications[j].Repository })
+	sort.Slice(h.RobotAccounts, func(i, j int) bool { return h.RobotAccounts[i].Name < h.RobotAccounts[j].Name })
+	return nil
+}
+
+// The bandwidth of proxy cache projects is declared with their registry.
+func exportProject(project api.Project, registries []api.Registry) Project {
+	exported := Project{Name: project.Name, Metadata: map[string]interface{}{}}
+	for k, v := range project.Metadata {
+		exported.Metadata[k] = v
+	}
+
+	for _, registry := range registries {
+		if project.RegistryID == 0 || registry.ID != project.RegistryID {
+			continue
+		}
+		exported.ProxyCache = &ProxyCache{Registry: registry.Name}
+		if speed, err := strconv.Atoi(project.Metadata["proxy_speed_kb"]); err == nil && speed != -1 {
+			exported.ProxyCache.Bandwidth = speed
+		}
+		delete(exported.Metadata, "proxy_speed_kb")
+	}
+	return exported
+}
+
+// JSON numbers are decoded as floats, which would be written in exponent notation.
+func exportConfigurationValue(value interface{}) interface{} {
+	if f, ok := value.(float64); ok && f == math.Trunc(f) {
+		return int64(f)
+	}
+	return value
+}
+
+// Only scheduled pull replications filtered by repository name can be declared, and
+// their name has to be the one platformer derives from the repository.
+func exportReplicationPolicy(policy api.ReplicationPolicy, registries []api.Registry) (ReplicationRule, error) {
+	rule := ReplicationRule{Description: helpers.UnmarkManaged(policy.Description)}
+	for _, filter := range policy.Filters {
+		if filter.Type == "name" {
+			rule.Repository = fmt.Sprint(filter.Value)
+		}
+	}
+	if rule.Repository == "" {
+		return rule, errors.New("it has no name filter")
+	}
+	if replicationRuleName(rule) != policy.Name {
+		return rule, fmt.Errorf("platformer would name it %s", replicationRuleName(rule))
+	}
+
+	if policy.SrcRegistry == nil || policy.SrcRegistry.ID == 0 {
+		return rule, errors.New("it does not pull from a registry")
+	}
+	for _, registry := range registries {
+		if registry.ID == policy.SrcRegistry.ID {
+			rule.SourceRegistry = registry.Name
+		}
+	}
+	if rule.SourceRegistry == "" {
+		return rule, fmt.Errorf("source registry %d not found", policy.SrcRegistry.ID)
+	}
+
+	if policy.Trigger == nil || policy.Trigger.Type != "scheduled" || policy.Trigger.TriggerSettings == nil {
+		return rule, errors.New("it is not scheduled")
+	}
+	rule.Crontab = policy.Trigger.TriggerSettings.Cron
+	rule.DestinationNamespace = policy.DestNamespace
+	return rule, nil
+}
+
+// exportRobotName strips the prefixes Harbor adds to the name of a robot.
+func exportRobotName(robot api.Robot) string {
+	name := strings.TrimPrefix(robot.Name, "robot$")
+	if robot.Level == "project" {
+		_, name, _ = strings.Cut(name, "+")
+	}
+	return name
+}
+
+// The permissions of a robot are declared once for all of its projects, so robots with
+// different permissions per project are not supported.
+func exportRobot(robot api.Robot, recorded state.Resource) (RobotAccount, error) {
+	account := RobotAccount{
+		Name:        exportRobotName(robot),
+		Description: helpers.UnmarkManaged(robot.Description),
+		Level:       robot.Level,
+	}
+	if account.Level != "project" {
+		account.Level = ""
+	}
+	if duration := declaredDuration(robot, recorded); duration > 0 {
+		account.Duration = int(duration)
+	}
+
+	var access string
+	for _, permission := range robot.Permissions {
+		if permission.Kind != "project" {
+			return RobotAccount{}, fmt.Errorf("permissions of kind %s are not supported", permission.Kind)
+		}
+		permissions := robotPermissions([]api.RobotPermission{{Access: permission.Access}})
+		if access != "" && permissions != access {
+			return RobotAccount{}, errors.New("it has different permissions per project")
+		}
+		access = permissions
+		account.Projects = append(account.Projects, permission.Namespace)
+	}
+	if len(account.Projects) == 0 {
+		return RobotAccount{}, errors.New("it has no project permissions")
+	}
+	sort.Strings(account.Projects)
+	if len(account.Projects) == 1 {
+		account.Project, account.Projects = account.Projects[0], nil
+	}
+
+	if len(robot.Permissions) > 0 && access != robotPermissions([]api.RobotPermission{{Access: robotAccess}}) {
+		account.Permissions = exportRobotPermissions(robot.Permissions[0].Access)
+	}
+	return account, nil
+}
+
+func exportRobotPermissions(access []api.Access) []RobotPermission {
+	var permissions []RobotPermission
+	actions := map[string][]string{}
+	for _, a := range access {
+		if _, ok := actions[a.Resource]; !ok {
+			permissions = append(permissions, RobotPermission{Resource: a.Resource})
+		}
+		actions[a.Resource] = append(actions[a.Resource], a.Action)
+	}
+	for i := range permissions {
+		permissions[i].Actions = actions[permissions[i].Resource]
+		sort.Strings(permissions[i].Actions)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Resource < permissions[j].Resource })
+	return permissions
+}
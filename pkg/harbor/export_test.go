@@ -0,0 +1,124 @@
+package harbor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
+	"testing"
+	"time"
+)
+
+// TestExportRobotRoundTrip checks that a run of an exported robot is a no-op.
+func TestExportRobotRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		robot    api.Robot
+		recorded string
+		duration int
+	}{
+		{
+			name:  "system robot",
+			robot: api.Robot{Name: "robot$ci", Level: "system", Duration: -1},
+		},
+		{
+			name:     "expiring robot",
+			robot:    api.Robot{Name: "robot$ci", Level: "system", Duration: 30},
+			duration: 30,
+		},
+		{
+			name:     "extended robot",
+			robot:    api.Robot{Name: "robot$ci", Level: "system", Duration: 75},
+			recorded: "30",
+			duration: 30,
+		},
+		{
+			name:     "project robot",
+			robot:    api.Robot{Name: "robot$library+ci", Level: "project", Duration: 7},
+			duration: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			robot := tt.robot
+			robot.Description = helpers.MarkManaged("")
+			robot.Permissions = []api.RobotPermission{{Kind: "project", Namespace: "library", Access: robotAccess}}
+			recorded := state.Resource{}
+			if tt.recorded != "" {
+				recorded.Attributes = map[string]string{"duration": tt.recorded}
+			}
+
+			account, err := exportRobot(robot, recorded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if account.Name != "ci" || account.Duration != tt.duration {
+				t.Errorf("expected robot ci with duration %d, got %+v", tt.duration, account)
+			}
+
+			change, _ := planRobotAccount(account, []api.Robot{robot}, recorded)
+			if change.Action != plan.ActionNoOp {
+				t.Errorf("expected %s, got %s with %+v", plan.ActionNoOp, change.Action, change.Diff)
+			}
+		})
+	}
+}
+
+// TestExportPlanRoundTrip checks that the exported config of resources platformer did
+// not create plans no changes, as adopting them only records them in the state.
+func TestExportPlanRoundTrip(t *testing.T) {
+	access, err := json.Marshal(robotAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt := time.Now().AddDate(0, 0, 30).Unix()
+
+	h, _ := fakeHarbor(t, map[string]string{
+		"GET /configurations": `{
+			"auth_mode": {"value": "db_auth", "editable": true},
+			"token_expiration": {"value": 30, "editable": true},
+			"self_registration": {"value": false, "editable": false}
+		}`,
+		"GET /projects": `[
+			{"project_id": 1, "name": "library", "metadata": {"public": "true"}},
+			{"project_id": 2, "name": "hub-cache", "registry_id": 3, "metadata": {"public": "false", "proxy_speed_kb": "-1"}}
+		]`,
+		"GET /projects/library":   `{"project_id": 1, "name": "library", "metadata": {"public": "true"}}`,
+		"GET /projects/hub-cache": `{"project_id": 2, "name": "hub-cache", "registry_id": 3, "metadata": {"public": "false", "proxy_speed_kb": "-1"}}`,
+		"GET /registries": `[
+			{"id": 3, "name": "hub", "url": "https://hub.docker.com", "type": "docker-hub", "description": "Docker Hub", "credential": {"access_key": "bot"}}
+		]`,
+		"GET /replication/policies": `[
+			{"id": 4, "name": "library-nginx", "description": "Mirror of nginx", "dest_namespace": "mirror", "enabled": true, "override": true,
+			 "src_registry": {"id": 3}, "filters": [{"type": "name", "value": "library/nginx"}],
+			 "trigger": {"type": "scheduled", "trigger_settings": {"cron": "0 0 2 * * *"}}}
+		]`,
+		"GET /robots": fmt.Sprintf(`[
+			{"id": 5, "name": "robot$ci", "level": "system", "duration": -1, "description": "CI",
+			 "permissions": [{"kind": "project", "namespace": "library", "access": %s}]},
+			{"id": 6, "name": "robot$library+deploy", "level": "project", "duration": 30, "expires_at": %d,
+			 "permissions": [{"kind": "project", "namespace": "library", "access": [{"resource": "repository", "action": "pull"}]}]}
+		]`, access, expiresAt),
+	})
+
+	err = h.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := h.Plan(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 8 {
+		t.Errorf("expected 8 changes, got %d: %+v", len(changes), changes)
+	}
+	for _, change := range changes {
+		if change.Action != plan.ActionNoOp {
+			t.Errorf("expected %s %s to be %s, got %s with %+v", change.Kind, change.Name, plan.ActionNoOp, change.Action, change.Diff)
+		}
+	}
+}
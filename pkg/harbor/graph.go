@@ -0,0 +1,119 @@
+package harbor
+
+import (
+	"fmt"
+	"github.com/thschue/platformer/pkg/graph"
+	"github.com/thschue/platformer/pkg/plan"
+)
+
+// Resources returns the declared Harbor resources accepted by the filter together with
+// their dependencies.
+func (h *Config) Resources(filter func(kind string, name string) bool) []graph.Node {
+	var nodes []graph.Node
+
+	for k, v := range h.Configuration {
+		if !filter(KindConfiguration, k) {
+			continue
+		}
+		k, v := k, v
+		nodes = append(nodes, graph.Node{
+			Kind: KindConfiguration,
+			Name: k,
+			Apply: func() (plan.Action, error) {
+				action, err := h.CreateConfiguration(k, v)
+				if err != nil {
+					return "", fmt.Errorf("error creating configuration: %w", err)
+				}
+				return action, nil
+			},
+		})
+	}
+
+	for _, project := range h.Projects {
+		if !filter(KindProject, project.Name) {
+			continue
+		}
+		project := project
+		var dependsOn []string
+		if project.ProxyCache != nil {
+			dependsOn = append(dependsOn, graph.ID(KindRegistry, project.ProxyCache.Registry))
+		}
+		nodes = append(nodes, graph.Node{
+			Kind:      KindProject,
+			Name:      project.Name,
+			DependsOn: dependsOn,
+			Apply: func() (plan.Action, error) {
+				action, err := h.CreateProject(project)
+				if err != nil {
+					return "", fmt.Errorf("error creating project: %w", err)
+				}
+				return action, nil
+			},
+		})
+	}
+
+	for _, registry := range h.Registries {
+		if !filter(KindRegistry, registry.Name) {
+			continue
+		}
+		registry := registry
+		nodes = append(nodes, graph.Node{
+			Kind: KindRegistry,
+			Name: registry.Name,
+			Apply: func() (plan.Action, error) {
+				action, err := h.CreateRegistry(registry)
+				if err != nil {
+					return "", fmt.Errorf("error creating registry: %w", err)
+				}
+				return action, nil
+			},
+		})
+	}
+
+	for _, rule := range h.Replications {
+		if !filter(KindReplication, rule.Repository) {
+			continue
+		}
+		rule := rule
+		nodes = append(nodes, graph.Node{
+			Kind: KindReplication,
+			Name: rule.Repository,
+			DependsOn: []string{
+				graph.ID(KindRegistry, rule.SourceRegistry),
+				graph.ID(KindProject, rule.DestinationNamespace),
+			},
+			Apply: func() (plan.Action, error) {
+				action, err := h.CreateReplicationRule(rule)
+				if err != nil {
+					return "", fmt.Errorf("error creating replication rule: %w", err)
+				}
+				return action, nil
+			},
+		})
+	}
+
+	for _, account := range h.RobotAccounts {
+		if !filter(KindRobotAccount, account.Name) {
+			continue
+		}
+		account := account
+		var dependsOn []string
+		for _, project := range robotProjects(account) {
+			dependsOn = append(dependsOn, graph.ID(KindProject, project))
+		}
+		nodes = append(nodes, graph.Node{
+			Kind:      KindRobotAccount,
+			Name:      account.Name,
+			DependsOn: dependsOn,
+			Apply: func() (plan.Action, error) {
+				action, err := h.CreateRobotAccount(account)
+				if err != nil {
+					return "", fmt.Errorf("error creating robot account: %w", err)
+				}
+				return action, nil
+			},
+		})
+	}
+
+	return nodes
+}
@@ -1,58 +1,70 @@
 package harbor
 
 import (
-	"bytes"
-	"crypto/tls"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/state"
+	"strings"
 )
 
-const configurationApi = "/api/v2.0/configurations"
-const projectApi = "/api/v2.0/projects"
-const registryApi = "/api/v2.0/registries"
-const replicationPolicyApi = "/api/v2.0/replication/policies"
-const robotAccountApi = "/api/v2.0/robots"
-const replicationExecutionApi = "/api/v2.0/replication/executions"
+const managedLabel = "managed-by-platformer"
 
-func (h *Config) IsAvailable() (bool, error) {
-	_, err := http.NewRequest("GET", h.Url, nil)
-	if err != nil {
-		fmt.Println(err)
-		return false, err
+func (h *Config) client() *api.Client {
+	h.clientMu.Lock()
+	defer h.clientMu.Unlock()
+
+	if h.apiClient == nil {
+		h.apiClient = api.New(h.Url, h.Credentials.Username, h.Credentials.Password.Value, h.TLSConfig.InsecureSkipVerify)
+		h.apiClient.DryRun = h.DryRun
 	}
-	return true, nil
+	return h.apiClient
 }
 
-func (h *Config) queryApi(method string, endpoint string, data map[string]interface{}) (io.ReadCloser, int, error) {
-	client := http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: h.TLSConfig.InsecureSkipVerify,
-			},
-		},
-	}
+// SetState records the IDs of the registries, replication rules and robots platformer
+// manages in s. Plans and prune match live objects by these IDs, so that objects
+// renamed in Harbor are renamed back instead of being recreated or left behind.
+func (h *Config) SetState(s *state.State) {
+	h.state = s
+}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return nil, 0, fmt.Errorf("error marshalling json: %w", err)
+// recordedId returns the ID the state recorded for a declared resource, or 0.
+func (h *Config) recordedId(kind string, name string) int64 {
+	r, _ := h.state.Get(kind, name)
+	return r.ID
+}
+
+// recordedNames maps the IDs the state recorded for a kind to the names of the
+// resources which own them.
+func (h *Config) recordedNames(kind string) map[int64]string {
+	names := map[int64]string{}
+	for _, r := range h.state.List() {
+		if r.Kind == kind && r.ID != 0 {
+			names[r.ID] = r.Name
+		}
 	}
+	return names
+}
 
-	req, err := http.NewRequest(method, endpoint, bytes.NewBuffer(jsonData))
+// IsAvailable checks that Harbor answers to ping and reports all of its components as healthy.
+func (h *Config) IsAvailable() (bool, error) {
+	err := h.client().Ping()
 	if err != nil {
-		fmt.Println(err)
-		return nil, 0, err
+		return false, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(h.Credentials.Username, h.Credentials.Password)
-
-	resp, err := client.Do(req)
+	health, err := h.client().Health()
 	if err != nil {
-		return resp.Body, resp.StatusCode, fmt.Errorf("error querying api: %w", err)
+		return false, err
 	}
 
-	return resp.Body, resp.StatusCode, nil
-
+	if health.Status != "healthy" {
+		var unhealthy []string
+		for _, component := range health.Components {
+			if component.Status != "healthy" {
+				unhealthy = append(unhealthy, component.Name)
+			}
+		}
+		return false, fmt.Errorf("harbor is %s, unhealthy components: %s", health.Status, strings.Join(unhealthy, ", "))
+	}
+	return true, nil
 }
@@ -0,0 +1,31 @@
+package harbor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeHarbor serves the given bodies by "METHOD /path" below /api/v2.0 and records the
+// requests it received. Unknown requests get a 404.
+func fakeHarbor(t *testing.T, responses map[string]string) (*Config, *[]string) {
+	t.Helper()
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path[len("/api/v2.0"):]
+		requests = append(requests, key)
+
+		body, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return &Config{Url: server.URL}, &requests
+}
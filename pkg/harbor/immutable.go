@@ -0,0 +1,116 @@
+package harbor
+
+import (
+	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"sort"
+	"strings"
+)
+
+func immutableRule(rule ImmutableRule) (api.ImmutableRule, error) {
+	tags, err := retentionSelector("matches", "excludes", rule.IncludeTags, rule.ExcludeTags)
+	if err != nil {
+		return api.ImmutableRule{}, fmt.Errorf("tags: %w", err)
+	}
+	repositories, err := retentionSelector("repoMatches", "repoExcludes", rule.IncludeRepositories, rule.ExcludeRepositories)
+	if err != nil {
+		return api.ImmutableRule{}, fmt.Errorf("repositories: %w", err)
+	}
+
+	return api.ImmutableRule{
+		Disabled:       rule.Disabled,
+		Action:         "immutable",
+		Template:       "immutable_template",
+		TagSelectors:   []api.RetentionSelector{tags},
+		ScopeSelectors: map[string][]api.RetentionSelector{"repository": {repositories}},
+	}, nil
+}
+
+func immutableRules(project Project) ([]api.ImmutableRule, error) {
+	var rules []api.ImmutableRule
+	for i, rule := range project.ImmutableRules {
+		r, err := immutableRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("immutableRules[%d]: %w", i, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// immutableRuleKey identifies a rule by its selectors, as rules have no name.
+func immutableRuleKey(rule api.ImmutableRule) string {
+	var selectors []string
+	for _, selector := range rule.TagSelectors {
+		selectors = append(selectors, fmt.Sprintf("tags %s %s", selector.Decoration, selector.Pattern))
+	}
+	for _, selector := range rule.ScopeSelectors["repository"] {
+		selectors = append(selectors, fmt.Sprintf("repositories %s %s", selector.Decoration, selector.Pattern))
+	}
+	return strings.Join(selectors, " ")
+}
+
+func immutableRulesString(rules []api.ImmutableRule) string {
+	var keys []string
+	for _, rule := range rules {
+		key := immutableRuleKey(rule)
+		if rule.Disabled {
+			key += " (disabled)"
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "; ")
+}
+
+// syncImmutableRules creates the declared rules, enables or disables them as declared
+// and deletes the rules which are not declared. Rules of a project which does not exist
+// yet are not looked up.
+func (h *Config) syncImmutableRules(project Project, exists bool) error {
+	if project.ImmutableRules == nil {
+		return nil
+	}
+
+	desired, err := immutableRules(project)
+	if err != nil {
+		return err
+	}
+
+	live := map[string]api.ImmutableRule{}
+	if exists || !h.DryRun {
+		rules, err := h.client().ListImmutableRules(project.Name)
+		if err != nil {
+			return fmt.Errorf("error getting immutability rules of project %s: %w", project.Name, err)
+		}
+		for _, rule := range rules {
+			live[immutableRuleKey(rule)] = rule
+		}
+	}
+
+	for _, rule := range desired {
+		key := immutableRuleKey(rule)
+		current, ok := live[key]
+		delete(live, key)
+		switch {
+		case !ok:
+			_, err := h.client().CreateImmutableRule(project.Name, rule)
+			if err != nil {
+				return fmt.Errorf("error creating immutability rule %q of project %s: %w", key, project.Name, err)
+			}
+		case current.Disabled != rule.Disabled:
+			rule.ID = current.ID
+			err := h.client().UpdateImmutableRule(project.Name, current.ID, rule)
+			if err != nil {
+				return fmt.Errorf("error updating immutability rule %q of project %s: %w", key, project.Name, err)
+			}
+		}
+	}
+
+	for key, rule := range live {
+		err := h.client().DeleteImmutableRule(project.Name, rule.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting immutability rule %q of project %s: %w", key, project.Name, err)
+		}
+	}
+	return nil
+}
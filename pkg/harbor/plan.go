@@ -0,0 +1,399 @@
+package harbor
+
+import (
+	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
+	"sort"
+	"strings"
+)
+
+const (
+	KindConfiguration = "harbor/configuration"
+	KindProject       = "harbor/project"
+	KindRegistry      = "harbor/registry"
+	KindReplication   = "harbor/replication"
+	KindRobotAccount  = "harbor/robot"
+)
+
+// Plan compares the declared Harbor resources with the live state of the Harbor instance.
+// With prune, undeclared resources created by platformer are planned for deletion.
+// Descriptions are compared without the ownership marker and projects without their
+// label, so adopting a resource is no change: the run records it in the state and it
+// is marked with its next update.
+func (h *Config) Plan(prune bool) ([]plan.Change, error) {
+	var changes []plan.Change
+
+	configChanges, err := h.planConfiguration()
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, configChanges...)
+
+	for _, project := range h.Projects {
+		change, _, err := h.planProject(project)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	registries, err := h.listRegistries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, registry := range h.Registries {
+		change, _ := planRegistry(registry, registries, h.recordedId(KindRegistry, registry.Name))
+		changes = append(changes, change)
+	}
+
+	policies, err := h.listReplicationPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range h.Replications {
+		change, _ := planReplicationRule(rule, policies, registries, h.recordedId(KindReplication, replicationRuleName(rule)))
+		changes = append(changes, change)
+	}
+
+	robots, err := h.listRobots()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, account := range h.RobotAccounts {
+		change, _ := planRobotAccount(account, robots, h.recordedRobot(account))
+		changes = append(changes, change)
+	}
+
+	if prune {
+		deletions, err := h.planPruneChanges()
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, deletions...)
+	}
+
+	return changes, nil
+}
+
+func (h *Config) planConfiguration() ([]plan.Change, error) {
+	live, err := h.client().GetConfigurations()
+	if err != nil {
+		return nil, fmt.Errorf("error getting configuration: %w", err)
+	}
+
+	keys := make([]string, 0, len(h.Configuration))
+	for k := range h.Configuration {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var changes []plan.Change
+	for _, k := range keys {
+		changes = append(changes, planConfigurationValue(live, k, h.Configuration[k]))
+	}
+	return changes, nil
+}
+
+func planConfigurationValue(live map[string]api.ConfigurationValue, key string, value interface{}) plan.Change {
+	var before map[string]interface{}
+	if current, ok := live[key]; ok {
+		before = map[string]interface{}{"value": current.Value}
+	}
+	return plan.NewChange(KindConfiguration, key, before, map[string]interface{}{"value": value})
+}
+
+func (h *Config) planProject(project Project) (plan.Change, *api.Project, error) {
+	desired := map[string]interface{}{}
+	for k, v := range projectMetadata(project) {
+		desired["metadata."+k] = v
+	}
+	if project.StorageLimit != "" {
+		limit, err := storageLimit(project)
+		if err != nil {
+			return plan.Change{}, nil, err
+		}
+		desired["storage_limit"] = limit
+	}
+	for _, member := range project.Members {
+		desired["members."+memberKey(member)] = member.Role
+	}
+	if project.CVEAllowlist != nil {
+		allowlist, err := cveAllowlist(project)
+		if err != nil {
+			return plan.Change{}, nil, err
+		}
+		desired["cve_allowlist"] = allowlistString(allowlist)
+	}
+	if project.Retention != nil {
+		policy, err := retentionPolicy(0, *project.Retention)
+		if err != nil {
+			return plan.Change{}, nil, err
+		}
+		desired["retention"] = retentionString(&policy)
+	}
+	if project.ImmutableRules != nil {
+		rules, err := immutableRules(project)
+		if err != nil {
+			return plan.Change{}, nil, err
+		}
+		desired["immutable_rules"] = immutableRulesString(rules)
+	}
+	if project.ProxyCache != nil {
+		desired["registry"] = project.ProxyCache.Registry
+	}
+	if project.Webhooks != nil {
+		policies := webhookPolicies(project)
+		desired["webhooks"] = webhookNames(policies)
+		for _, policy := range policies {
+			for k, v := range webhookValues(policy) {
+				desired[k] = v
+			}
+		}
+	}
+
+	live, err := h.client().GetProject(project.Name)
+	if api.IsNotFound(err) {
+		return plan.NewChange(KindProject, project.Name, nil, desired), nil, nil
+	}
+	if err != nil {
+		return plan.Change{}, nil, fmt.Errorf("error getting project %s: %w", project.Name, err)
+	}
+
+	before := map[string]interface{}{
+		"cve_allowlist": allowlistString(live.CVEAllowlist),
+	}
+	for k, v := range live.Metadata {
+		before["metadata."+k] = v
+	}
+
+	// Quota, members, retention, immutability rules, webhooks and the proxy cache registry
+	// are only looked up when declared.
+	if project.ProxyCache != nil {
+		before["registry"] = ""
+		if live.RegistryID != 0 {
+			registries, err := h.listRegistries()
+			if err != nil {
+				return plan.Change{}, nil, err
+			}
+			for _, registry := range registries {
+				if registry.ID == live.RegistryID {
+					before["registry"] = registry.Name
+				}
+			}
+		}
+	}
+	if project.StorageLimit != "" {
+		quota, err := h.client().GetProjectQuota(live.ProjectID)
+		if err != nil {
+			return plan.Change{}, nil, fmt.Errorf("error getting quota of project %s: %w", project.Name, err)
+		}
+		before["storage_limit"], _ = storageQuota(quota)
+	}
+	if len(project.Members) > 0 {
+		members, err := h.client().ListProjectMembers(project.Name)
+		if err != nil {
+			return plan.Change{}, nil, fmt.Errorf("error getting members of project %s: %w", project.Name, err)
+		}
+		for _, member := range members {
+			before["members."+liveMemberKey(member)] = roleName(member.RoleID)
+		}
+	}
+	if project.Retention != nil {
+		policy, err := h.liveRetention(live)
+		if err != nil {
+			return plan.Change{}, nil, err
+		}
+		before["retention"] = retentionString(policy)
+	}
+	if project.ImmutableRules != nil {
+		rules, err := h.client().ListImmutableRules(project.Name)
+		if err != nil {
+			return plan.Change{}, nil, fmt.Errorf("error getting immutability rules of project %s: %w", project.Name, err)
+		}
+		before["immutable_rules"] = immutableRulesString(rules)
+	}
+	if project.Webhooks != nil {
+		policies, err := h.client().ListWebhookPolicies(project.Name)
+		if err != nil {
+			return plan.Change{}, nil, fmt.Errorf("error getting webhooks of project %s: %w", project.Name, err)
+		}
+		before["webhooks"] = webhookNames(policies)
+		for _, policy := range policies {
+			for k, v := range webhookValues(policy) {
+				before[k] = v
+			}
+		}
+	}
+	return plan.NewChange(KindProject, project.Name, before, desired), live, nil
+}
+
+func (h *Config) listRegistries() ([]api.Registry, error) {
+	registries, err := h.client().ListRegistries()
+	if err != nil {
+		return nil, fmt.Errorf("error getting registries: %w", err)
+	}
+	return registries, nil
+}
+
+// planRegistry finds the registry by name, or by the ID recorded in the state when it
+// was renamed in Harbor.
+func planRegistry(registry Registry, registries []api.Registry, recordedId int64) (plan.Change, *api.Registry) {
+	desired := map[string]interface{}{
+		"name":                  registry.Name,
+		"description":           registry.Description,
+		"url":                   registry.Url,
+		"type":                  registry.Type,
+		"credential.access_key": registry.Credentials.AccessKey,
+	}
+
+	for _, live := range registries {
+		if live.Name != registry.Name && (recordedId == 0 || live.ID != recordedId) {
+			continue
+		}
+		before := map[string]interface{}{
+			"name":                  live.Name,
+			"description":           helpers.UnmarkManaged(live.Description),
+			"url":                   live.URL,
+			"type":                  live.Type,
+			"credential.access_key": "",
+		}
+		if live.Credential != nil {
+			before["credential.access_key"] = live.Credential.AccessKey
+		}
+		return plan.NewChange(KindRegistry, registry.Name, before, desired), &live
+	}
+	return plan.NewChange(KindRegistry, registry.Name, nil, desired), nil
+}
+
+func (h *Config) listReplicationPolicies() ([]api.ReplicationPolicy, error) {
+	policies, err := h.client().ListReplicationPolicies()
+	if err != nil {
+		return nil, fmt.Errorf("error getting replication rules: %w", err)
+	}
+	return policies, nil
+}
+
+func replicationRuleName(rule ReplicationRule) string {
+	return strings.Replace(rule.Repository, "/", "-", -1)
+}
+
+// planReplicationRule finds the policy by name, or by the ID recorded in the state when
+// it was renamed in Harbor.
+func planReplicationRule(rule ReplicationRule, policies []api.ReplicationPolicy, registries []api.Registry, recordedId int64) (plan.Change, *api.ReplicationPolicy) {
+	name := replicationRuleName(rule)
+	desired := map[string]interface{}{
+		"name":           name,
+		"description":    rule.Description,
+		"dest_namespace": rule.DestinationNamespace,
+		"enabled":        true,
+		"override":       true,
+		"src_registry":   rule.SourceRegistry,
+		"filters.name":   rule.Repository,
+		"trigger.cron":   rule.Crontab,
+	}
+
+	for _, live := range policies {
+		if live.Name != name && (recordedId == 0 || live.ID != recordedId) {
+			continue
+		}
+
+		before := map[string]interface{}{
+			"name":           live.Name,
+			"description":    helpers.UnmarkManaged(live.Description),
+			"dest_namespace": live.DestNamespace,
+			"enabled":        live.Enabled,
+			"override":       live.Override,
+		}
+		if live.SrcRegistry != nil {
+			before["src_registry"] = live.SrcRegistry.ID
+			for _, registry := range registries {
+				if registry.ID == live.SrcRegistry.ID {
+					before["src_registry"] = registry.Name
+				}
+			}
+		}
+		if live.Trigger != nil && live.Trigger.TriggerSettings != nil {
+			before["trigger.cron"] = live.Trigger.TriggerSettings.Cron
+		}
+		for _, filter := range live.Filters {
+			if filter.Type == "name" {
+				before["filters.name"] = filter.Value
+			}
+		}
+		return plan.NewChange(KindReplication, rule.Repository, before, desired), &live
+	}
+	return plan.NewChange(KindReplication, rule.Repository, nil, desired), nil
+}
+
+func (h *Config) listRobots() ([]api.Robot, error) {
+	robots, err := h.client().ListRobots()
+	if err != nil {
+		return nil, fmt.Errorf("error getting robot accounts: %w", err)
+	}
+	return robots, nil
+}
+
+func robotPermissions(permissions []api.RobotPermission) string {
+	var namespaces []string
+	for _, permission := range permissions {
+		var actions []string
+		for _, a := range permission.Access {
+			actions = append(actions, a.Resource+":"+a.Action)
+		}
+		sort.Strings(actions)
+		namespaces = append(namespaces, permission.Namespace+" "+strings.Join(actions, ","))
+	}
+	sort.Strings(namespaces)
+	return strings.Join(namespaces, "; ")
+}
+
+// planRobotAccount looks the robot up by the name Harbor gave it.
+func planRobotAccount(account RobotAccount, robots []api.Robot, recorded state.Resource) (plan.Change, *api.Robot) {
+	desired := map[string]interface{}{
+		"name":        robotName(account),
+		"description": account.Description,
+		"level":       robotLevel(account),
+		"disable":     false,
+		"permissions": robotPermissions(robotAccountPermissions(account)),
+		"duration":    robotDuration(account),
+		"rotate":      false,
+	}
+
+	live := findRobot(account, robots, recorded)
+	if live == nil {
+		return plan.NewChange(KindRobotAccount, account.Name, nil, desired), nil
+	}
+
+	before := map[string]interface{}{
+		"name":        live.Name,
+		"description": helpers.UnmarkManaged(live.Description),
+		"level":       live.Level,
+		"disable":     live.Disable,
+		"permissions": robotPermissions(live.Permissions),
+		"duration":    declaredDuration(*live, recorded),
+		"rotate":      rotationDue(account, *live),
+	}
+	return plan.NewChange(KindRobotAccount, account.Name, before, desired), live
+}
+
+// findRobot finds the robot by name, or by the ID recorded in the state when its level
+// or projects changed, which gives it another name in Harbor.
+func findRobot(account RobotAccount, robots []api.Robot, recorded state.Resource) *api.Robot {
+	for _, live := range robots {
+		if live.Name == robotName(account) {
+			return &live
+		}
+	}
+	for _, live := range robots {
+		if recorded.ID != 0 && live.ID == recorded.ID {
+			return &live
+		}
+	}
+	return nil
+}
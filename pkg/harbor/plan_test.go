@@ -0,0 +1,170 @@
+package harbor
+
+import (
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
+	"testing"
+)
+
+func TestPlanProjectStorageLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  string
+		quota  string
+		action plan.Action
+	}{
+		{name: "same limit", limit: "10Gi", quota: "10737418240", action: plan.ActionNoOp},
+		{name: "large limit", limit: "5Ti", quota: "5497558138880", action: plan.ActionNoOp},
+		{name: "unlimited", limit: "-1", quota: "-1", action: plan.ActionNoOp},
+		{name: "different limit", limit: "20Gi", quota: "10737418240", action: plan.ActionUpdate},
+		{name: "limit on unlimited project", limit: "10Gi", quota: "-1", action: plan.ActionUpdate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := fakeHarbor(t, map[string]string{
+				"GET /projects/library": `{"project_id": 3, "name": "library", "metadata": {}}`,
+				"GET /labels":           `[{"id": 1, "name": "managed-by-platformer"}]`,
+				"GET /quotas":           `[{"id": 7, "hard": {"storage": ` + tt.quota + `}}]`,
+			})
+
+			change, _, err := h.planProject(Project{Name: "library", StorageLimit: tt.limit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if change.Action != tt.action {
+				t.Errorf("expected %s, got %s with %+v", tt.action, change.Action, change.Diff)
+			}
+		})
+	}
+}
+
+func TestPlanRegistryRecordedId(t *testing.T) {
+	registries := []api.Registry{
+		{ID: 3, Name: "renamed", URL: "https://hub.docker.com", Type: "docker-hub", Description: helpers.MarkManaged("")},
+	}
+
+	tests := []struct {
+		name       string
+		recordedId int64
+		action     plan.Action
+		id         int64
+	}{
+		{name: "not recorded", action: plan.ActionCreate},
+		{name: "recorded", recordedId: 3, action: plan.ActionUpdate, id: 3},
+		{name: "recorded for another registry", recordedId: 4, action: plan.ActionCreate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := Registry{Name: "hub", Url: "https://hub.docker.com", Type: "docker-hub"}
+			change, live := planRegistry(registry, registries, tt.recordedId)
+			if change.Action != tt.action {
+				t.Errorf("expected %s, got %s with %+v", tt.action, change.Action, change.Diff)
+			}
+			if live != nil && live.ID != tt.id {
+				t.Errorf("expected registry %d, got %d", tt.id, live.ID)
+			}
+		})
+	}
+}
+
+func TestPlanRobotAccountDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration int
+		live     int64
+		recorded string
+		action   plan.Action
+	}{
+		{name: "never expires", live: -1, action: plan.ActionNoOp},
+		{name: "same duration", duration: 30, live: 30, action: plan.ActionNoOp},
+		{name: "extended duration", duration: 30, live: 75, recorded: "30", action: plan.ActionNoOp},
+		{name: "changed duration", duration: 90, live: 75, recorded: "30", action: plan.ActionUpdate},
+		{name: "changed duration without record", duration: 90, live: 30, action: plan.ActionUpdate},
+		{name: "expiry removed", live: 30, recorded: "30", action: plan.ActionUpdate},
+		{name: "expiry added", duration: 30, live: -1, recorded: "-1", action: plan.ActionUpdate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := RobotAccount{Name: "ci", Project: "library", Duration: tt.duration}
+			robot := api.Robot{
+				ID:          5,
+				Name:        "robot$ci",
+				Description: helpers.MarkManaged(""),
+				Level:       "system",
+				Duration:    tt.live,
+				Permissions: robotAccountPermissions(account),
+			}
+			recorded := state.Resource{}
+			if tt.recorded != "" {
+				recorded.Attributes = map[string]string{"duration": tt.recorded}
+			}
+
+			change, _ := planRobotAccount(account, []api.Robot{robot}, recorded)
+			if change.Action != tt.action {
+				t.Errorf("expected %s, got %s with %+v", tt.action, change.Action, change.Diff)
+			}
+		})
+	}
+}
+
+func TestPlanRobotAccountReplaced(t *testing.T) {
+	tests := []struct {
+		name       string
+		account    RobotAccount
+		live       api.Robot
+		recordedId int64
+		action     plan.Action
+		replaced   bool
+	}{
+		{
+			name:    "same level",
+			account: RobotAccount{Name: "ci", Project: "library"},
+			live:    api.Robot{ID: 5, Name: "robot$ci", Level: "system"},
+			action:  plan.ActionNoOp,
+		},
+		{
+			name:       "project robot becomes system robot",
+			account:    RobotAccount{Name: "ci", Project: "library"},
+			live:       api.Robot{ID: 5, Name: "robot$library+ci", Level: "project"},
+			recordedId: 5,
+			action:     plan.ActionUpdate,
+			replaced:   true,
+		},
+		{
+			name:       "system robot becomes project robot",
+			account:    RobotAccount{Name: "ci", Level: "project", Project: "library"},
+			live:       api.Robot{ID: 5, Name: "robot$ci", Level: "system"},
+			recordedId: 5,
+			action:     plan.ActionUpdate,
+			replaced:   true,
+		},
+		{
+			name:    "other level without record",
+			account: RobotAccount{Name: "ci", Level: "project", Project: "library"},
+			live:    api.Robot{ID: 5, Name: "robot$ci", Level: "system"},
+			action:  plan.ActionCreate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			robot := tt.live
+			robot.Description = helpers.MarkManaged("")
+			robot.Duration = -1
+			robot.Permissions = robotAccountPermissions(tt.account)
+
+			change, live := planRobotAccount(tt.account, []api.Robot{robot}, state.Resource{ID: tt.recordedId})
+			if change.Action != tt.action {
+				t.Errorf("expected %s, got %s with %+v", tt.action, change.Action, change.Diff)
+			}
+			if live != nil && robotReplaced(tt.account, *live) != tt.replaced {
+				t.Errorf("expected replaced %t, got %t", tt.replaced, !tt.replaced)
+			}
+		})
+	}
+}
@@ -2,30 +2,333 @@ package harbor
 
 import (
 	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"log"
+	"sort"
+	"strings"
+	"time"
 )
 
-func (h *Config) CreateProject(project Project) error {
-	jsonData := map[string]interface{}{
-		"project_name": project.Name,
-		"metadata":     project.Metadata,
+var memberRoles = map[string]int64{
+	"projectAdmin": 1,
+	"developer":    2,
+	"guest":        3,
+	"maintainer":   4,
+}
+
+// projectMetadata converts the declared metadata to the string values Harbor expects.
+// A declared CVE allowlist only takes effect when the system allowlist is not reused.
+func projectMetadata(project Project) map[string]string {
+	metadata := map[string]string{}
+	for k, v := range project.Metadata {
+		metadata[k] = fmt.Sprint(v)
+	}
+	if _, ok := metadata["reuse_sys_cve_allowlist"]; !ok && project.CVEAllowlist != nil {
+		metadata["reuse_sys_cve_allowlist"] = "false"
+	}
+	if project.ProxyCache != nil {
+		metadata["proxy_speed_kb"] = fmt.Sprint(proxySpeed(*project.ProxyCache))
+	}
+	return metadata
+}
+
+func proxySpeed(cache ProxyCache) int {
+	if cache.Bandwidth == 0 {
+		return -1
 	}
+	return cache.Bandwidth
+}
+
+func storageLimit(project Project) (int64, error) {
+	limit, err := resource.ParseQuantity(project.StorageLimit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid storageLimit %q: %w", project.StorageLimit, err)
+	}
+	return limit.Value(), nil
+}
 
-	_, errorCode, err := h.queryApi("POST", h.Url+projectApi, jsonData)
-	if err != nil && errorCode != 409 {
-		return fmt.Errorf("error creating project: %w", err)
+func cveAllowlist(project Project) (*api.CVEAllowlist, error) {
+	if project.CVEAllowlist == nil {
+		return nil, nil
 	}
 
-	if errorCode == 409 {
-		log.Println("Project already exists, updating project")
-		_, errorCode, err = h.queryApi("PUT", h.Url+projectApi+"/"+project.Name, jsonData)
+	allowlist := &api.CVEAllowlist{Items: []api.CVEAllowlistItem{}}
+	for _, id := range project.CVEAllowlist.Items {
+		allowlist.Items = append(allowlist.Items, api.CVEAllowlistItem{CVEID: id})
+	}
+
+	if project.CVEAllowlist.ExpiresAt != "" {
+		expiresAt, err := parseExpiry(project.CVEAllowlist.ExpiresAt)
 		if err != nil {
-			return fmt.Errorf("error updating project: %w", err)
+			return nil, fmt.Errorf("invalid cveAllowlist.expiresAt %q: %w", project.CVEAllowlist.ExpiresAt, err)
+		}
+		unix := expiresAt.Unix()
+		allowlist.ExpiresAt = &unix
+	}
+	return allowlist, nil
+}
+
+func parseExpiry(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// allowlistString renders an allowlist independently of the order of its items.
+func allowlistString(allowlist *api.CVEAllowlist) string {
+	if allowlist == nil {
+		return ""
+	}
+
+	var ids []string
+	for _, item := range allowlist.Items {
+		ids = append(ids, item.CVEID)
+	}
+	sort.Strings(ids)
+
+	s := strings.Join(ids, ",")
+	if allowlist.ExpiresAt != nil {
+		s += " expires " + time.Unix(*allowlist.ExpiresAt, 0).UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+func memberKey(member ProjectMember) string {
+	if member.Group != "" {
+		return "group:" + member.Group
+	}
+	return "user:" + member.User
+}
+
+func liveMemberKey(member api.ProjectMember) string {
+	if member.EntityType == "g" {
+		return "group:" + member.EntityName
+	}
+	return "user:" + member.EntityName
+}
+
+func roleName(id int64) string {
+	for name, roleID := range memberRoles {
+		if roleID == id {
+			return name
+		}
+	}
+	return fmt.Sprint(id)
+}
+
+func (h *Config) CreateProject(project Project) (plan.Action, error) {
+	change, live, err := h.planProject(project)
+	if err != nil {
+		return "", err
+	}
+
+	allowlist, err := cveAllowlist(project)
+	if err != nil {
+		return "", err
+	}
+
+	req := api.ProjectReq{
+		ProjectName:  project.Name,
+		Metadata:     projectMetadata(project),
+		CVEAllowlist: allowlist,
+	}
+
+	switch change.Action {
+	case plan.ActionCreate:
+		if project.StorageLimit != "" {
+			limit, err := storageLimit(project)
+			if err != nil {
+				return "", err
+			}
+			req.StorageLimit = &limit
+		}
+		if project.ProxyCache != nil {
+			registryId, err := h.getRegistryId(project.ProxyCache.Registry)
+			if err != nil {
+				return "", fmt.Errorf("error creating proxy cache project %s: %w", project.Name, err)
+			}
+			req.RegistryID = &registryId
+		}
+
+		id, err := h.client().CreateProject(req)
+		if err != nil {
+			return "", fmt.Errorf("error creating project: %w", err)
+		}
+		err = h.markProjectManaged(id)
+		if err != nil {
+			return "", err
+		}
+		err = h.syncMembers(project, false)
+		if err != nil {
+			return "", err
+		}
+		err = h.syncRetention(id, nil, project)
+		if err != nil {
+			return "", err
+		}
+		err = h.syncImmutableRules(project, false)
+		if err != nil {
+			return "", err
+		}
+		err = h.syncWebhooks(project, false)
+		if err != nil {
+			return "", err
 		}
-		log.Println(fmt.Sprintf("Project %s updated", project.Name))
-	} else {
 		log.Println(fmt.Sprintf("Project %s created", project.Name))
+		h.state.Set(state.Resource{Kind: KindProject, Name: project.Name, ID: id})
+	case plan.ActionUpdate:
+		if project.ProxyCache != nil {
+			registryId, err := h.getRegistryId(project.ProxyCache.Registry)
+			if err != nil {
+				return "", fmt.Errorf("error updating proxy cache project %s: %w", project.Name, err)
+			}
+			if live.RegistryID != registryId {
+				return "", fmt.Errorf("the proxy cache registry of project %s can only be set when it is created", project.Name)
+			}
+		}
+		err = h.client().UpdateProject(project.Name, req)
+		if err != nil {
+			return "", fmt.Errorf("error updating project: %w", err)
+		}
+		err = h.markProjectManaged(live.ProjectID)
+		if err != nil {
+			return "", err
+		}
+		err = h.syncQuota(live.ProjectID, project)
+		if err != nil {
+			return "", err
+		}
+		err = h.syncMembers(project, true)
+		if err != nil {
+			return "", err
+		}
+		err = h.syncRetention(live.ProjectID, live, project)
+		if err != nil {
+			return "", err
+		}
+		err = h.syncImmutableRules(project, true)
+		if err != nil {
+			return "", err
+		}
+		err = h.syncWebhooks(project, true)
+		if err != nil {
+			return "", err
+		}
+		log.Println(fmt.Sprintf("Project %s updated", project.Name))
+		h.state.Set(state.Resource{Kind: KindProject, Name: project.Name, ID: live.ProjectID})
+	default:
+		log.Println(fmt.Sprintf("Project %s is up to date", project.Name))
+		h.state.Set(state.Resource{Kind: KindProject, Name: project.Name, ID: live.ProjectID})
+	}
+
+	return change.Action, nil
+}
+
+// Projects have no description, so ownership is recorded as a project-scoped label.
+func (h *Config) isProjectManaged(projectId int64) (bool, error) {
+	labels, err := h.client().ListProjectLabels(projectId, managedLabel)
+	if err != nil {
+		return false, fmt.Errorf("error getting project labels: %w", err)
+	}
+	return len(labels) > 0, nil
+}
+
+func (h *Config) markProjectManaged(projectId int64) error {
+	if !h.DryRun {
+		managed, err := h.isProjectManaged(projectId)
+		if err != nil || managed {
+			return err
+		}
+	}
+
+	_, err := h.client().CreateLabel(api.Label{
+		Name:        managedLabel,
+		Description: "Project is managed by platformer",
+		Color:       "#0065AB",
+		Scope:       "p",
+		ProjectID:   projectId,
+	})
+	if err != nil {
+		return fmt.Errorf("error labelling project: %w", err)
+	}
+	return nil
+}
+
+// storageQuota returns the storage limit of a quota. JSON numbers are decoded as floats.
+func storageQuota(quota *api.Quota) (int64, bool) {
+	current, ok := quota.Hard["storage"].(float64)
+	return int64(current), ok
+}
+
+func (h *Config) syncQuota(projectId int64, project Project) error {
+	if project.StorageLimit == "" {
+		return nil
+	}
+
+	limit, err := storageLimit(project)
+	if err != nil {
+		return err
+	}
+
+	quota, err := h.client().GetProjectQuota(projectId)
+	if err != nil {
+		return fmt.Errorf("error getting quota of project %s: %w", project.Name, err)
+	}
+	if current, ok := storageQuota(quota); ok && current == limit {
+		return nil
 	}
 
+	err = h.client().UpdateQuota(quota.ID, api.QuotaUpdate{Hard: map[string]int64{"storage": limit}})
+	if err != nil {
+		return fmt.Errorf("error updating quota of project %s: %w", project.Name, err)
+	}
+	return nil
+}
+
+// syncMembers adds the declared members and corrects their roles. Members of a project
+// which does not exist yet are not looked up.
+func (h *Config) syncMembers(project Project, exists bool) error {
+	if len(project.Members) == 0 {
+		return nil
+	}
+
+	live := map[string]api.ProjectMember{}
+	if exists || !h.DryRun {
+		members, err := h.client().ListProjectMembers(project.Name)
+		if err != nil {
+			return fmt.Errorf("error getting members of project %s: %w", project.Name, err)
+		}
+		for _, member := range members {
+			live[liveMemberKey(member)] = member
+		}
+	}
+
+	for _, member := range project.Members {
+		role := memberRoles[member.Role]
+		current, ok := live[memberKey(member)]
+		switch {
+		case !ok:
+			req := api.ProjectMemberReq{RoleID: role}
+			if member.Group != "" {
+				req.MemberGroup = &api.UserGroupEntity{GroupName: member.Group}
+			} else {
+				req.MemberUser = &api.UserEntity{Username: member.User}
+			}
+			_, err := h.client().CreateProjectMember(project.Name, req)
+			if err != nil {
+				return fmt.Errorf("error adding %s to project %s: %w", memberKey(member), project.Name, err)
+			}
+		case current.RoleID != role:
+			err := h.client().UpdateProjectMember(project.Name, current.ID, api.RoleRequest{RoleID: role})
+			if err != nil {
+				return fmt.Errorf("error updating %s in project %s: %w", memberKey(member), project.Name, err)
+			}
+		}
+	}
 	return nil
 }
@@ -0,0 +1,259 @@
+package harbor
+
+import (
+	"context"
+	"fmt"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"log"
+	"strings"
+)
+
+const KindArgoSecret = "harbor/argocd-secret"
+
+type deletion struct {
+	kind string
+	name string
+	// id is the Harbor ID of the deleted resource, if it has one. Only the state
+	// record of that ID is removed.
+	id     int64
+	delete func() error
+}
+
+// planPrune lists the resources platformer created in Harbor which are no longer declared,
+// in the order they have to be deleted. Only resources carrying an ownership marker or
+// whose ID is recorded in the state are considered. Resources are matched to the
+// declared ones by name and by the recorded ID, so renaming one in Harbor does not get
+// it deleted.
+func (h *Config) planPrune() ([]deletion, error) {
+	var deletions []deletion
+
+	policies, err := h.listReplicationPolicies()
+	if err != nil {
+		return nil, err
+	}
+	recordedPolicies := h.recordedNames(KindReplication)
+	declaredPolicies := map[string]bool{}
+	declaredPolicyIds := map[int64]bool{}
+	for _, rule := range h.Replications {
+		declaredPolicies[replicationRuleName(rule)] = true
+		declaredPolicyIds[h.recordedId(KindReplication, replicationRuleName(rule))] = true
+	}
+	for _, policy := range policies {
+		recorded, ok := recordedPolicies[policy.ID]
+		if declaredPolicies[policy.Name] || declaredPolicyIds[policy.ID] || !(ok || helpers.IsManaged(policy.Description)) {
+			continue
+		}
+		if !ok {
+			recorded = policy.Name
+		}
+		id := policy.ID
+		deletions = append(deletions, deletion{kind: KindReplication, name: recorded, id: id, delete: func() error {
+			return h.client().DeleteReplicationPolicy(id)
+		}})
+	}
+
+	robots, err := h.planPruneRobots()
+	if err != nil {
+		return nil, err
+	}
+	deletions = append(deletions, robots...)
+
+	registries, err := h.listRegistries()
+	if err != nil {
+		return nil, err
+	}
+	recordedRegistries := h.recordedNames(KindRegistry)
+	declaredRegistries := map[string]bool{}
+	declaredRegistryIds := map[int64]bool{}
+	for _, registry := range h.Registries {
+		declaredRegistries[registry.Name] = true
+		declaredRegistryIds[h.recordedId(KindRegistry, registry.Name)] = true
+	}
+	for _, registry := range registries {
+		recorded, ok := recordedRegistries[registry.ID]
+		if declaredRegistries[registry.Name] || declaredRegistryIds[registry.ID] || !(ok || helpers.IsManaged(registry.Description)) {
+			continue
+		}
+		if !ok {
+			recorded = registry.Name
+		}
+		id := registry.ID
+		deletions = append(deletions, deletion{kind: KindRegistry, name: recorded, id: id, delete: func() error {
+			return h.client().DeleteRegistry(id)
+		}})
+	}
+
+	projects, err := h.client().ListProjects()
+	if err != nil {
+		return nil, fmt.Errorf("error getting projects: %w", err)
+	}
+	declaredProjects := map[string]bool{}
+	for _, project := range h.Projects {
+		declaredProjects[project.Name] = true
+	}
+	recordedProjects := h.recordedNames(KindProject)
+	for _, project := range projects {
+		if declaredProjects[project.Name] {
+			continue
+		}
+		_, managed := recordedProjects[project.ProjectID]
+		if !managed {
+			managed, err = h.isProjectManaged(project.ProjectID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if managed {
+			name := project.Name
+			deletions = append(deletions, deletion{kind: KindProject, name: name, delete: func() error {
+				return h.client().DeleteProject(name)
+			}})
+		}
+	}
+
+	secrets, err := h.planPruneSecrets()
+	if err != nil {
+		return nil, err
+	}
+	return append(deletions, secrets...), nil
+}
+
+// planPruneRobots lists the robots platformer created which are no longer declared.
+// A robot whose ID is recorded for a declared account is kept, even after it was
+// renamed in Harbor.
+func (h *Config) planPruneRobots() ([]deletion, error) {
+	var deletions []deletion
+
+	robots, err := h.listRobots()
+	if err != nil {
+		return nil, err
+	}
+	recordedRobots := h.recordedNames(KindRobotAccount)
+	declaredRobots := map[string]bool{}
+	declaredRobotIds := map[int64]bool{}
+	for _, account := range h.RobotAccounts {
+		declaredRobots[robotName(account)] = true
+		declaredRobotIds[h.recordedId(KindRobotAccount, account.Name)] = true
+	}
+	for _, robot := range robots {
+		name, ok := recordedRobots[robot.ID]
+		if declaredRobots[robot.Name] || declaredRobotIds[robot.ID] || !(ok || helpers.IsManaged(robot.Description)) {
+			continue
+		}
+		if !ok {
+			name = strings.TrimPrefix(robot.Name, "robot$")
+		}
+		id := robot.ID
+		deletions = append(deletions, deletion{kind: KindRobotAccount, name: name, id: id, delete: func() error {
+			return h.client().DeleteRobot(id)
+		}})
+	}
+	return deletions, nil
+}
+
+// argoSecrets returns the names of the ArgoCD secrets platformer created for robots.
+func (h *Config) argoSecrets() ([]string, error) {
+	clientset, err := helpers.KubernetesClient()
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := clientset.CoreV1().Secrets(argoNamespace).List(context.TODO(), v1.ListOptions{
+		LabelSelector: helpers.ManagedByLabel + "=" + helpers.ManagedBy + "," + helpers.ComponentLabel + "=harbor",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	var names []string
+	for _, secret := range secrets.Items {
+		names = append(names, secret.Name)
+	}
+	return names, nil
+}
+
+func (h *Config) planPruneSecrets() ([]deletion, error) {
+	secrets, err := h.argoSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	declared := map[string]bool{}
+	for _, account := range h.RobotAccounts {
+		declared[robotSecretName(account)] = true
+	}
+
+	var deletions []deletion
+	for _, secret := range secrets {
+		if !declared[secret] {
+			name := argoNamespace + "/" + secret
+			deletions = append(deletions, deletion{kind: KindArgoSecret, name: name, delete: func() error {
+				return h.deleteKubernetesSecret(name)
+			}})
+		}
+	}
+	return deletions, nil
+}
+
+func (h *Config) planPruneChanges() ([]plan.Change, error) {
+	deletions, err := h.planPrune()
+	if err != nil {
+		return nil, err
+	}
+	return deletionChanges(deletions), nil
+}
+
+// Prune deletes the Harbor resources and ArgoCD secrets platformer created which are
+// no longer declared. The filter decides which of those deletions are applied.
+func (h *Config) Prune(filter func(kind string, name string) bool) ([]plan.Change, error) {
+	deletions, err := h.planPrune()
+	if err != nil {
+		return nil, err
+	}
+	return h.applyDeletions(deletions, filter)
+}
+
+// applyDeletions deletes in order and stops at the first failure.
+func (h *Config) applyDeletions(deletions []deletion, filter func(kind string, name string) bool) ([]plan.Change, error) {
+	var deleted []plan.Change
+	for _, d := range deletions {
+		if !filter(d.kind, d.name) {
+			continue
+		}
+
+		err := d.delete()
+		if err != nil {
+			return deleted, fmt.Errorf("error deleting %s %s: %w", d.kind, d.name, err)
+		}
+		log.Println(fmt.Sprintf("Deleted %s %s", d.kind, d.name))
+		if d.id == 0 || h.recordedId(d.kind, d.name) == d.id {
+			h.state.Delete(d.kind, d.name)
+		}
+		deleted = append(deleted, plan.Change{Kind: d.kind, Name: d.name, Action: plan.ActionDelete})
+	}
+	return deleted, nil
+}
+
+func deletionChanges(deletions []deletion) []plan.Change {
+	var changes []plan.Change
+	for _, d := range deletions {
+		changes = append(changes, plan.Change{Kind: d.kind, Name: d.name, Action: plan.ActionDelete})
+	}
+	return changes
+}
+
+func (h *Config) deleteKubernetesSecret(name string) error {
+	namespace, secretName, _ := strings.Cut(name, "/")
+	if h.DryRun {
+		helpers.LogDryRun("DELETE", "secret "+name, nil)
+		return nil
+	}
+
+	clientset, err := helpers.KubernetesClient()
+	if err != nil {
+		return err
+	}
+	return clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), secretName, v1.DeleteOptions{})
+}
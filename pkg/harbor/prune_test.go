@@ -0,0 +1,99 @@
+package harbor
+
+import (
+	"github.com/thschue/platformer/pkg/state"
+	"slices"
+	"testing"
+)
+
+func TestPlanPruneRobots(t *testing.T) {
+	robots := `[
+		{"id": 3, "name": "robot$renamed", "description": "hand written"},
+		{"id": 4, "name": "robot$old", "description": "hand written"},
+		{"id": 5, "name": "robot$manual", "description": "hand written"}
+	]`
+
+	tests := []struct {
+		name     string
+		declared []RobotAccount
+		recorded []state.Resource
+		deleted  []string
+	}{
+		{
+			name:     "recorded robot no longer declared",
+			recorded: []state.Resource{{Kind: KindRobotAccount, Name: "old", ID: 4}},
+			deleted:  []string{"old"},
+		},
+		{
+			name:     "declared robot renamed in harbor",
+			declared: []RobotAccount{{Name: "ci"}},
+			recorded: []state.Resource{{Kind: KindRobotAccount, Name: "ci", ID: 3}},
+		},
+		{
+			name:     "declared robot and undeclared robot",
+			declared: []RobotAccount{{Name: "ci"}},
+			recorded: []state.Resource{
+				{Kind: KindRobotAccount, Name: "ci", ID: 3},
+				{Kind: KindRobotAccount, Name: "old", ID: 4},
+			},
+			deleted: []string{"old"},
+		},
+		{name: "robot not created by platformer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := fakeHarbor(t, map[string]string{"GET /robots": robots})
+			h.RobotAccounts = tt.declared
+			s := state.New()
+			for _, r := range tt.recorded {
+				s.Set(r)
+			}
+			h.SetState(s)
+
+			deletions, err := h.planPruneRobots()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var deleted []string
+			for _, d := range deletions {
+				deleted = append(deleted, d.name)
+			}
+			if !slices.Equal(deleted, tt.deleted) {
+				t.Errorf("expected %v, got %v", tt.deleted, deleted)
+			}
+		})
+	}
+}
+
+func TestApplyDeletionsState(t *testing.T) {
+	tests := []struct {
+		name     string
+		deletion deletion
+		recorded int64
+		kept     bool
+	}{
+		{name: "recorded id", deletion: deletion{kind: KindRobotAccount, name: "ci", id: 3}, recorded: 3},
+		{name: "other recorded id", deletion: deletion{kind: KindRobotAccount, name: "ci", id: 4}, recorded: 3, kept: true},
+		{name: "without id", deletion: deletion{kind: KindRobotAccount, name: "ci"}, recorded: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := state.New()
+			s.Set(state.Resource{Kind: KindRobotAccount, Name: "ci", ID: tt.recorded})
+			h := &Config{}
+			h.SetState(s)
+
+			d := tt.deletion
+			d.delete = func() error { return nil }
+			_, err := h.applyDeletions([]deletion{d}, func(string, string) bool { return true })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, kept := s.Get(KindRobotAccount, "ci"); kept != tt.kept {
+				t.Errorf("expected record kept %t, got %t", tt.kept, kept)
+			}
+		})
+	}
+}
@@ -1,55 +1,77 @@
 package harbor
 
 import (
-	"encoding/json"
 	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
 	"log"
+	"slices"
 )
 
-func (h *Config) CreateRegistry(registry Registry) error {
-	jsonData := map[string]interface{}{
-		"name":        registry.Name,
-		"description": registry.Description,
-		"url":         registry.Url,
-		"type":        registry.Type,
-		"credential": map[string]interface{}{
-			"access_key":    registry.Credentials.AccessKey,
-			"access_secret": registry.Credentials.AccessSecret,
-		},
-	}
-
-	_, errorCode, err := h.queryApi("POST", h.Url+registryApi, jsonData)
-	if err != nil && errorCode != 409 {
-		return fmt.Errorf("error creating registry: %w", err)
+func (h *Config) CreateRegistry(registry Registry) (plan.Action, error) {
+	registries, err := h.listRegistries()
+	if err != nil {
+		return "", err
 	}
+	change, live := planRegistry(registry, registries, h.recordedId(KindRegistry, registry.Name))
 
-	if errorCode == 409 {
-		log.Println("Registry already exists, updating registry")
-		_, errorCode, err = h.queryApi("PUT", h.Url+registryApi+"/"+registry.Name, jsonData)
+	switch change.Action {
+	case plan.ActionCreate:
+		id, err := h.client().CreateRegistry(api.Registry{
+			Name:        registry.Name,
+			Description: helpers.MarkManaged(registry.Description),
+			URL:         registry.Url,
+			Type:        registry.Type,
+			Credential: &api.RegistryCredential{
+				Type:         "basic",
+				AccessKey:    registry.Credentials.AccessKey,
+				AccessSecret: registry.Credentials.AccessSecret.Value,
+			},
+		})
 		if err != nil {
-			return fmt.Errorf("error updating registry: %w", err)
+			return "", fmt.Errorf("error creating registry: %w", err)
 		}
-		log.Println(fmt.Sprintf("Registry %s updated", registry.Name))
-	} else {
 		log.Println(fmt.Sprintf("Registry %s created", registry.Name))
+		h.state.Set(state.Resource{Kind: KindRegistry, Name: registry.Name, ID: id})
+	case plan.ActionUpdate:
+		err = h.client().UpdateRegistry(live.ID, api.RegistryUpdate{
+			Name:           registry.Name,
+			Description:    helpers.MarkManaged(registry.Description),
+			URL:            registry.Url,
+			CredentialType: "basic",
+			AccessKey:      registry.Credentials.AccessKey,
+			AccessSecret:   registry.Credentials.AccessSecret.Value,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error updating registry: %w", err)
+		}
+		log.Println(fmt.Sprintf("Registry %s updated", registry.Name))
+		h.state.Set(state.Resource{Kind: KindRegistry, Name: registry.Name, ID: live.ID})
+	default:
+		log.Println(fmt.Sprintf("Registry %s is up to date", registry.Name))
+		h.state.Set(state.Resource{Kind: KindRegistry, Name: registry.Name, ID: live.ID})
 	}
-	return nil
+	return change.Action, nil
 }
 
-func (h *Config) getRegistryId(name string) interface{} {
-	registries, _, err := h.queryApi("GET", h.Url+registryApi, nil)
+// getRegistryId looks up a registry by name. In dry-run mode a declared registry which
+// does not exist yet would have been created, so it resolves to 0.
+func (h *Config) getRegistryId(name string) (int64, error) {
+	registries, err := h.listRegistries()
 	if err != nil {
-		fmt.Println("Error getting registries")
+		return 0, err
 	}
 
-	var registryList []map[string]interface{}
-
-	json.NewDecoder(registries).Decode(&registryList)
-
-	for _, registry := range registryList {
-		if registry["name"] == name {
-			return registry["id"]
+	for _, registry := range registries {
+		if registry.Name == name {
+			return registry.ID, nil
 		}
 	}
-	return 999
+
+	if h.DryRun && slices.ContainsFunc(h.Registries, func(r Registry) bool { return r.Name == name }) {
+		return 0, nil
+	}
+	return 0, fmt.Errorf("registry %s not found", name)
 }
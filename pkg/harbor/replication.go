@@ -1,77 +1,103 @@
 package harbor
 
 import (
-	"encoding/json"
 	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
 	"log"
-	"strings"
 )
 
-func (h *Config) CreateReplicationRule(rule ReplicationRule) error {
-	src := h.getRegistryId(rule.SourceRegistry)
+func (h *Config) CreateReplicationRule(rule ReplicationRule) (plan.Action, error) {
+	registries, err := h.listRegistries()
+	if err != nil {
+		return "", err
+	}
+	policies, err := h.listReplicationPolicies()
+	if err != nil {
+		return "", err
+	}
+	change, live := planReplicationRule(rule, policies, registries, h.recordedId(KindReplication, replicationRuleName(rule)))
+
+	src, err := h.getRegistryId(rule.SourceRegistry)
+	if err != nil {
+		return "", fmt.Errorf("error getting source registry of replication rule %s: %w", rule.Repository, err)
+	}
 
-	jsonData := map[string]interface{}{
-		"dest_namespace": rule.DestinationNamespace,
-		"enabled":        true,
-		"name":           strings.Replace(rule.Repository, "/", "-", -1),
-		"override":       true,
-		"src_registry": map[string]interface{}{
-			"id": src,
+	policy := api.ReplicationPolicy{
+		Name:          replicationRuleName(rule),
+		Description:   helpers.MarkManaged(rule.Description),
+		DestNamespace: rule.DestinationNamespace,
+		Enabled:       true,
+		Override:      true,
+		SrcRegistry: &api.RegistryRef{
+			ID: src,
 		},
-		"filters": []map[string]interface{}{
+		Filters: []api.ReplicationFilter{
 			{
-				"type":  "name",
-				"value": rule.Repository,
+				Type:  "name",
+				Value: rule.Repository,
 			},
 		},
-		"trigger": map[string]interface{}{
-			"type": "scheduled",
-			"trigger_settings": map[string]string{
-				"cron": rule.Crontab,
+		Trigger: &api.ReplicationTrigger{
+			Type: "scheduled",
+			TriggerSettings: &api.TriggerSettings{
+				Cron: rule.Crontab,
 			},
 		},
 	}
 
-	_, errorCode, err := h.queryApi("POST", h.Url+replicationPolicyApi, jsonData)
-	if err != nil && errorCode != 409 {
-		return fmt.Errorf("error creating replication rule: %w", err)
-	}
+	switch change.Action {
+	case plan.ActionCreate:
+		id, err := h.client().CreateReplicationPolicy(policy)
+		if err != nil {
+			return "", fmt.Errorf("error creating replication rule: %w", err)
+		}
+
+		if id == 0 && !h.DryRun {
+			id, err = h.getReplicationRuleId(policy.Name)
+			if err != nil {
+				return "", err
+			}
+		}
 
-	if errorCode == 201 {
-		id, err := h.getReplicationRuleId(strings.Replace(rule.Repository, "/", "-", -1))
 		err = h.runReplicationRule(id)
 		if err != nil {
-			return fmt.Errorf("error running replication rule: %w", err)
+			return "", fmt.Errorf("error running replication rule: %w", err)
 		}
 		log.Println(fmt.Sprintf("Replication rule %s started", rule.Repository))
+		h.state.Set(state.Resource{Kind: KindReplication, Name: policy.Name, ID: id})
+	case plan.ActionUpdate:
+		err = h.client().UpdateReplicationPolicy(live.ID, policy)
+		if err != nil {
+			return "", fmt.Errorf("error updating replication rule: %w", err)
+		}
+		log.Println(fmt.Sprintf("Replication rule %s updated", rule.Repository))
+		h.state.Set(state.Resource{Kind: KindReplication, Name: policy.Name, ID: live.ID})
+	default:
+		log.Println(fmt.Sprintf("Replication rule %s is up to date", rule.Repository))
+		h.state.Set(state.Resource{Kind: KindReplication, Name: policy.Name, ID: live.ID})
 	}
-	return nil
+	return change.Action, nil
 }
 
-func (h *Config) getReplicationRuleId(name string) (interface{}, error) {
-	replicationRules, _, err := h.queryApi("GET", h.Url+replicationPolicyApi, nil)
+func (h *Config) getReplicationRuleId(name string) (int64, error) {
+	policies, err := h.listReplicationPolicies()
 	if err != nil {
-		return nil, fmt.Errorf("error getting replication rules: %w", err)
+		return 0, err
 	}
 
-	var replicationRuleList []map[string]interface{}
-
-	json.NewDecoder(replicationRules).Decode(&replicationRuleList)
-
-	for _, rule := range replicationRuleList {
-		if rule["name"] == name {
-			return rule["id"], nil
+	for _, policy := range policies {
+		if policy.Name == name {
+			return policy.ID, nil
 		}
 	}
-	return 999, fmt.Errorf("replication rule not found")
+	return 0, fmt.Errorf("replication rule %s not found", name)
 }
 
-func (h *Config) runReplicationRule(ruleId interface{}) error {
-	jsonData := map[string]interface{}{
-		"policy_id": ruleId,
-	}
-
-	_, _, err := h.queryApi("POST", h.Url+replicationExecutionApi, jsonData)
+func (h *Config) runReplicationRule(ruleId int64) error {
+	_, err := h.client().StartReplicationExecution(ruleId)
 	if err != nil {
 		return fmt.Errorf("error running replication rule: %w", err)
 	}
@@ -0,0 +1,174 @@
+package harbor
+
+import (
+	"errors"
+	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"strconv"
+	"strings"
+)
+
+// retentionPolicy converts the declared retention to the policy of a project.
+func retentionPolicy(projectId int64, retention Retention) (api.RetentionPolicy, error) {
+	policy := api.RetentionPolicy{
+		Algorithm: "or",
+		Rules:     []api.RetentionRule{},
+		Trigger: &api.RetentionTrigger{
+			Kind:     "Schedule",
+			Settings: map[string]interface{}{"cron": retention.Schedule},
+		},
+		Scope: &api.RetentionScope{
+			Level: "project",
+			Ref:   projectId,
+		},
+	}
+
+	for i, rule := range retention.Rules {
+		template, value, err := retentionTemplate(rule)
+		if err != nil {
+			return policy, fmt.Errorf("retention.rules[%d]: %w", i, err)
+		}
+		tags, err := retentionSelector("matches", "excludes", rule.IncludeTags, rule.ExcludeTags)
+		if err != nil {
+			return policy, fmt.Errorf("retention.rules[%d]: tags: %w", i, err)
+		}
+		repositories, err := retentionSelector("repoMatches", "repoExcludes", rule.IncludeRepositories, rule.ExcludeRepositories)
+		if err != nil {
+			return policy, fmt.Errorf("retention.rules[%d]: repositories: %w", i, err)
+		}
+
+		policy.Rules = append(policy.Rules, api.RetentionRule{
+			Action:         "retain",
+			Template:       template,
+			Params:         map[string]interface{}{template: value},
+			TagSelectors:   []api.RetentionSelector{tags},
+			ScopeSelectors: map[string][]api.RetentionSelector{"repository": {repositories}},
+		})
+	}
+	return policy, nil
+}
+
+func retentionTemplate(rule RetentionRule) (string, int, error) {
+	templates := map[string]int{
+		"latestPushedK":      rule.KeepMostRecent,
+		"latestPulledN":      rule.KeepRecentlyPulled,
+		"nDaysSinceLastPush": rule.KeepPushedWithinDays,
+		"nDaysSinceLastPull": rule.KeepPulledWithinDays,
+	}
+
+	var template string
+	for name, value := range templates {
+		if value == 0 {
+			continue
+		}
+		if template != "" {
+			return "", 0, errors.New("only one of keepMostRecent, keepRecentlyPulled, keepPushedWithinDays and keepPulledWithinDays may be set")
+		}
+		if value < 0 {
+			return "", 0, fmt.Errorf("%d is not a positive number", value)
+		}
+		template = name
+	}
+	if template == "" {
+		return "", 0, errors.New("one of keepMostRecent, keepRecentlyPulled, keepPushedWithinDays and keepPulledWithinDays is required")
+	}
+	return template, templates[template], nil
+}
+
+func retentionSelector(include string, exclude string, includePattern string, excludePattern string) (api.RetentionSelector, error) {
+	switch {
+	case includePattern != "" && excludePattern != "":
+		return api.RetentionSelector{}, errors.New("include and exclude are mutually exclusive")
+	case excludePattern != "":
+		return api.RetentionSelector{Kind: "doublestar", Decoration: exclude, Pattern: excludePattern}, nil
+	case includePattern != "":
+		return api.RetentionSelector{Kind: "doublestar", Decoration: include, Pattern: includePattern}, nil
+	}
+	return api.RetentionSelector{Kind: "doublestar", Decoration: include, Pattern: "**"}, nil
+}
+
+// retentionString renders the parts of a policy which are declared, so that a live
+// policy can be compared with the desired one.
+func retentionString(policy *api.RetentionPolicy) string {
+	if policy == nil {
+		return ""
+	}
+
+	var rules []string
+	for _, rule := range policy.Rules {
+		s := fmt.Sprintf("%s=%v", rule.Template, rule.Params[rule.Template])
+		for _, selector := range rule.TagSelectors {
+			s += fmt.Sprintf(" tags %s %s", selector.Decoration, selector.Pattern)
+		}
+		for _, selector := range rule.ScopeSelectors["repository"] {
+			s += fmt.Sprintf(" repositories %s %s", selector.Decoration, selector.Pattern)
+		}
+		rules = append(rules, s)
+	}
+
+	s := strings.Join(rules, "; ")
+	if policy.Trigger != nil && policy.Trigger.Settings["cron"] != "" {
+		s += fmt.Sprintf(" schedule %v", policy.Trigger.Settings["cron"])
+	}
+	return s
+}
+
+// retentionId returns the policy Harbor linked to a project.
+func retentionId(project *api.Project) (int64, bool) {
+	if project == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(project.Metadata["retention_id"], 10, 64)
+	return id, err == nil && id > 0
+}
+
+func (h *Config) liveRetention(project *api.Project) (*api.RetentionPolicy, error) {
+	id, ok := retentionId(project)
+	if !ok {
+		return nil, nil
+	}
+
+	policy, err := h.client().GetRetention(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting retention policy of project %s: %w", project.Name, err)
+	}
+	return policy, nil
+}
+
+// syncRetention updates the policy linked to the project, or creates one if there is
+// none, so that a project never ends up with several policies.
+func (h *Config) syncRetention(projectId int64, live *api.Project, project Project) error {
+	if project.Retention == nil {
+		return nil
+	}
+
+	policy, err := retentionPolicy(projectId, *project.Retention)
+	if err != nil {
+		return err
+	}
+
+	current, err := h.liveRetention(live)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		_, err = h.client().CreateRetention(policy)
+		if err != nil {
+			return fmt.Errorf("error creating retention policy of project %s: %w", project.Name, err)
+		}
+		return nil
+	}
+
+	if retentionString(current) == retentionString(&policy) {
+		return nil
+	}
+
+	id, _ := retentionId(live)
+	policy.ID = id
+	err = h.client().UpdateRetention(id, policy)
+	if err != nil {
+		return fmt.Errorf("error updating retention policy of project %s: %w", project.Name, err)
+	}
+	return nil
+}
@@ -1,86 +1,307 @@
 package harbor
 
 import (
-	"encoding/json"
 	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/plan"
+	"github.com/thschue/platformer/pkg/state"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type RobotResponse struct {
-	Name   string `json:"name"`
-	Secret string `json:"secret"`
-}
-
-func (h *Config) CreateRobotAccount(account RobotAccount) error {
-	data := map[string]interface{}{
-		"disable":    false,
-		"duration":   -1,
-		"editable":   false,
-		"expires_at": -1,
-		"level":      "system",
-		"name":       account.Name,
-		"permissions": []map[string]interface{}{
-			{
-				"access": []map[string]string{
-					{
-						"action":   "list",
-						"resource": "artifact",
-					},
-					{
-						"action":   "read",
-						"resource": "artifact",
-					},
-					{
-						"action":   "list",
-						"resource": "repository",
-					},
-					{
-						"action":   "pull",
-						"resource": "repository",
-					},
-					{
-						"action":   "read",
-						"resource": "repository",
-					},
-					{
-						"action":   "list",
-						"resource": "tag",
-					},
-				},
-				"kind":      "project",
-				"namespace": account.Project,
-			},
-		},
-	}
-
-	resp, errorCode, err := h.queryApi("POST", h.Url+robotAccountApi, data)
-	if err != nil && errorCode != 409 {
-		return fmt.Errorf("error creating robot: %w", err)
+var robotAccess = []api.Access{
+	{
+		Action:   "list",
+		Resource: "artifact",
+	},
+	{
+		Action:   "read",
+		Resource: "artifact",
+	},
+	{
+		Action:   "list",
+		Resource: "repository",
+	},
+	{
+		Action:   "pull",
+		Resource: "repository",
+	},
+	{
+		Action:   "read",
+		Resource: "repository",
+	},
+	{
+		Action:   "list",
+		Resource: "tag",
+	},
+}
+
+func robotLevel(account RobotAccount) string {
+	if account.Level == "" {
+		return "system"
+	}
+	return account.Level
+}
+
+func robotProjects(account RobotAccount) []string {
+	if account.Project != "" {
+		return append([]string{account.Project}, account.Projects...)
+	}
+	return account.Projects
+}
+
+// robotName is the name Harbor gives the robot.
+func robotName(account RobotAccount) string {
+	if robotLevel(account) == "project" {
+		return "robot$" + strings.Join(robotProjects(account), ",") + "+" + account.Name
+	}
+	return "robot$" + account.Name
+}
+
+func robotAccountAccess(account RobotAccount) []api.Access {
+	if len(account.Permissions) == 0 {
+		return robotAccess
+	}
+
+	var access []api.Access
+	for _, permission := range account.Permissions {
+		for _, action := range permission.Actions {
+			access = append(access, api.Access{Resource: permission.Resource, Action: action})
+		}
+	}
+	return access
+}
+
+func robotAccountPermissions(account RobotAccount) []api.RobotPermission {
+	var permissions []api.RobotPermission
+	for _, project := range robotProjects(account) {
+		permissions = append(permissions, api.RobotPermission{
+			Access:    robotAccountAccess(account),
+			Kind:      "project",
+			Namespace: project,
+		})
+	}
+	return permissions
+}
+
+func robotDuration(account RobotAccount) int64 {
+	if account.Duration <= 0 {
+		return -1
+	}
+	return int64(account.Duration)
+}
+
+// declaredDuration returns the duration the robot was last applied with. Harbor grows
+// the duration of a robot whenever its expiry is extended, so the declared one is
+// recorded in the state. Robots without a record fall back to the duration in Harbor.
+func declaredDuration(robot api.Robot, recorded state.Resource) int64 {
+	duration, err := strconv.ParseInt(recorded.Attributes["duration"], 10, 64)
+	if err == nil {
+		return duration
+	}
+	if robot.Duration <= 0 {
+		return -1
+	}
+	return robot.Duration
+}
+
+// robotExpiry is the expiry of a secret issued now.
+func robotExpiry(account RobotAccount) int64 {
+	if robotDuration(account) == -1 {
+		return 0
+	}
+	return time.Now().AddDate(0, 0, account.Duration).Unix()
+}
+
+func rotateBefore(account RobotAccount) time.Duration {
+	days := account.RotateBefore
+	if days == 0 {
+		days = 7
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// rotationDue reports whether the secret of the robot expires within the rotation
+// window of the account.
+func rotationDue(account RobotAccount, robot api.Robot) bool {
+	if robot.ExpiresAt <= 0 {
+		return false
+	}
+	return time.Until(time.Unix(robot.ExpiresAt, 0)) < rotateBefore(account)
+}
+
+// extendedDuration makes the robot expire the declared number of days from now. Harbor
+// counts the duration from the creation of the robot.
+func extendedDuration(account RobotAccount, robot api.Robot) int64 {
+	if robotDuration(account) == -1 || robot.CreationTime.IsZero() {
+		return robotDuration(account)
+	}
+	return int64(time.Since(robot.CreationTime).Hours()/24) + robotDuration(account)
+}
+
+func robotResource(account RobotAccount, id int64, expiresAt int64) state.Resource {
+	resource := state.Resource{Kind: KindRobotAccount, Name: account.Name, ID: id, Attributes: map[string]string{
+		"duration": strconv.FormatInt(robotDuration(account), 10),
+	}}
+	if expiresAt > 0 {
+		resource.Attributes["expires_at"] = time.Unix(expiresAt, 0).UTC().Format(time.RFC3339)
+	}
+	return resource
+}
+
+func (h *Config) recordedRobot(account RobotAccount) state.Resource {
+	recorded, _ := h.state.Get(KindRobotAccount, account.Name)
+	return recorded
+}
+
+func (h *Config) CreateRobotAccount(account RobotAccount) (plan.Action, error) {
+	robots, err := h.listRobots()
+	if err != nil {
+		return "", err
+	}
+	recorded := h.recordedRobot(account)
+	change, live := planRobotAccount(account, robots, recorded)
+
+	// Harbor never returns the secret of a robot, so a broken ArgoCD secret can only be
+	// repaired with a new one. It is only checked when applying, so that planning works
+	// without access to the cluster.
+	repair := false
+	if live != nil && !h.DryRun {
+		status, err := h.argoSecretStatus(*live, robotSecretName(account))
+		if err != nil {
+			return "", err
+		}
+		repair = status != "ok"
 	}
 
-	if errorCode == 409 {
-		log.Println("Robot already exists, updating robot")
-		resp, errorCode, err = h.queryApi("PUT", h.Url+robotAccountApi+"/"+account.Name, data)
+	switch change.Action {
+	case plan.ActionCreate:
+		err = h.createRobot(account)
 		if err != nil {
-			return fmt.Errorf("error updating robot: %w", err)
+			return "", err
+		}
+	case plan.ActionUpdate:
+		// Harbor cannot change the level or the projects of a robot, so it is replaced.
+		if robotReplaced(account, *live) {
+			err = h.client().DeleteRobot(live.ID)
+			if err != nil {
+				return "", fmt.Errorf("error deleting robot %s to replace it: %w", live.Name, err)
+			}
+			log.Println(fmt.Sprintf("Robot %s deleted to be replaced", live.Name))
+			err = h.createRobot(account)
+			if err != nil {
+				return "", err
+			}
+			return change.Action, nil
+		}
+
+		robot := *live
+		robot.Description = helpers.MarkManaged(account.Description)
+		robot.Disable = false
+		robot.Permissions = robotAccountPermissions(account)
+		expiresAt := live.ExpiresAt
+		if rotationDue(account, *live) || declaredDuration(*live, recorded) != robotDuration(account) {
+			robot.Duration = extendedDuration(account, *live)
+			expiresAt = robotExpiry(account)
+		}
+
+		err = h.client().UpdateRobot(live.ID, robot)
+		if err != nil {
+			return "", fmt.Errorf("error updating robot: %w", err)
 		}
 		log.Println(fmt.Sprintf("Robot %s updated", account.Name))
-	} else {
-		log.Println(fmt.Sprintf("Robot %s created", account.Name))
+		h.state.Set(robotResource(account, live.ID, expiresAt))
+
+		if rotationDue(account, *live) || repair {
+			err = h.rotateSecret(account, *live)
+			if err != nil {
+				return "", err
+			}
+		}
+	default:
+		if repair {
+			err = h.rotateSecret(account, *live)
+			if err != nil {
+				return "", err
+			}
+			return plan.ActionUpdate, nil
+		}
+		log.Println(fmt.Sprintf("Robot %s is up to date", account.Name))
+		h.state.Set(robotResource(account, live.ID, live.ExpiresAt))
+	}
+
+	return change.Action, nil
+}
+
+func (h *Config) createRobot(account RobotAccount) error {
+	created, err := h.client().CreateRobot(api.RobotCreate{
+		Name:        account.Name,
+		Description: helpers.MarkManaged(account.Description),
+		Level:       robotLevel(account),
+		Duration:    robotDuration(account),
+		Permissions: robotAccountPermissions(account),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating robot: %w", err)
 	}
+	log.Println(fmt.Sprintf("Robot %s created", account.Name))
+	h.state.Set(robotResource(account, created.ID, created.ExpiresAt))
+
+	// A secret left behind by an earlier robot of the same name holds a stale secret.
+	err = h.updateKubernetesSecretForArgoCD(argoNamespace, RobotAccount{Name: created.Name, Token: created.Secret}, robotSecretName(account))
+	if err != nil {
+		return fmt.Errorf("error creating ArgoCD secret of robot %s: %w", account.Name, err)
+	}
+	return nil
+}
 
-	var response RobotResponse
-	err = json.NewDecoder(resp).Decode(&response)
+// robotReplaced reports whether the live robot has another level or other projects
+// than declared, which only a new robot can have.
+func robotReplaced(account RobotAccount, live api.Robot) bool {
+	return live.Level != robotLevel(account) || live.Name != robotName(account)
+}
+
+// RotateRobotAccount gives the robot a new secret, extends its expiry by the declared
+// duration and updates its ArgoCD secret.
+func (h *Config) RotateRobotAccount(account RobotAccount) error {
+	robots, err := h.listRobots()
 	if err != nil {
-		log.Println("Could not decode response")
+		return err
 	}
 
-	fmt.Println(response)
+	for _, live := range robots {
+		if live.Name != robotName(account) {
+			continue
+		}
+
+		if robotDuration(account) != -1 {
+			robot := live
+			robot.Duration = extendedDuration(account, live)
+			err = h.client().UpdateRobot(live.ID, robot)
+			if err != nil {
+				return fmt.Errorf("error extending robot %s: %w", account.Name, err)
+			}
+		}
+		return h.rotateSecret(account, live)
+	}
+	return fmt.Errorf("robot %s not found", account.Name)
+}
 
-	err = h.createKubernetesSecretForArgoCD("argocd", RobotAccount{Name: response.Name, Token: response.Secret}, "helm-"+account.Name)
+func (h *Config) rotateSecret(account RobotAccount, robot api.Robot) error {
+	secret, err := h.client().RefreshRobotSecret(robot.ID)
 	if err != nil {
-		log.Println("Could not create secret")
+		return fmt.Errorf("error refreshing secret of robot %s: %w", account.Name, err)
 	}
+	log.Println(fmt.Sprintf("Secret of robot %s rotated", account.Name))
+
+	h.state.Set(robotResource(account, robot.ID, robotExpiry(account)))
 
+	err = h.updateKubernetesSecretForArgoCD(argoNamespace, RobotAccount{Name: robot.Name, Token: secret}, robotSecretName(account))
+	if err != nil {
+		return fmt.Errorf("error updating ArgoCD secret of robot %s: %w", account.Name, err)
+	}
 	return nil
 }
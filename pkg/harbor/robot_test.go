@@ -0,0 +1,45 @@
+package harbor
+
+import (
+	"bytes"
+	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/state"
+	"log"
+	"strings"
+	"testing"
+)
+
+// TestCreateRobotAccountReplaced checks that a robot whose level changed is deleted and
+// created again with a new ArgoCD secret, as Harbor cannot update its level.
+func TestCreateRobotAccountReplaced(t *testing.T) {
+	h, _ := fakeHarbor(t, map[string]string{
+		"GET /robots": `[{"id": 5, "name": "robot$ci", "level": "system", "duration": -1, "description": "` + helpers.MarkManaged("") + `"}]`,
+	})
+	h.DryRun = true
+	s := state.New()
+	s.Set(state.Resource{Kind: KindRobotAccount, Name: "ci", ID: 5})
+	h.SetState(s)
+
+	var logs bytes.Buffer
+	out := log.Writer()
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	_, err := h.CreateRobotAccount(RobotAccount{Name: "ci", Level: "project", Project: "library"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if _, request, ok := strings.Cut(line, "[dry-run] "); ok {
+			method, target, _ := strings.Cut(request, " ")
+			target, _, _ = strings.Cut(target, " ")
+			requests = append(requests, method+" "+strings.TrimPrefix(target, h.Url+"/api/v2.0"))
+		}
+	}
+	expected := []string{"DELETE /robots/5", "POST /robots", "UPDATE secret"}
+	if strings.Join(requests, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected %v, got %v", expected, requests)
+	}
+}
@@ -1,47 +1,159 @@
 package harbor
 
 import (
+	"github.com/thschue/platformer/pkg/harbor/api"
 	"github.com/thschue/platformer/pkg/helpers"
+	"github.com/thschue/platformer/pkg/state"
+	"sync"
 )
 
 type Config struct {
 	Url           string                 `yaml:"url"`
-	Configuration map[string]interface{} `yaml:"configuration"`
-	Projects      []Project              `yaml:"projects"`
-	Registries    []Registry             `yaml:"registries"`
-	Replications  []ReplicationRule      `yaml:"replications"`
+	Configuration map[string]interface{} `yaml:"configuration,omitempty"`
+	Projects      []Project              `yaml:"projects,omitempty"`
+	Registries    []Registry             `yaml:"registries,omitempty"`
+	Replications  []ReplicationRule      `yaml:"replications,omitempty"`
 	Credentials   helpers.Credentials    `yaml:"credentials"`
-	TLSConfig     helpers.TlsConfig      `yaml:"tlsConfig"`
-	RobotAccounts []RobotAccount         `yaml:"robotAccounts"`
+	TLSConfig     helpers.TlsConfig      `yaml:"tlsConfig,omitempty"`
+	RobotAccounts []RobotAccount         `yaml:"robotAccounts,omitempty"`
+	DryRun        bool                   `yaml:"-" json:"-" mapstructure:"-"`
+
+	apiClient *api.Client
+	clientMu  sync.Mutex
+	state     *state.State
 }
 
 type Project struct {
 	Name     string                 `yaml:"name"`
-	Metadata map[string]interface{} `yaml:"metadata"`
+	Metadata map[string]interface{} `yaml:"metadata,omitempty"`
+	// StorageLimit is the storage quota, e.g. 10Gi, or -1 for no limit.
+	StorageLimit string          `yaml:"storageLimit,omitempty"`
+	Members      []ProjectMember `yaml:"members,omitempty"`
+	CVEAllowlist *CVEAllowlist   `yaml:"cveAllowlist,omitempty"`
+	Retention    *Retention      `yaml:"retention,omitempty"`
+	// ImmutableRules replace all immutability rules of the project when set, an empty
+	// list removes them.
+	ImmutableRules []ImmutableRule `yaml:"immutableRules,omitempty"`
+	// Webhooks replace all webhooks of the project when set, an empty list removes them.
+	Webhooks []Webhook `yaml:"webhooks,omitempty"`
+	// ProxyCache makes the project a pull-through cache. It can only be set when the
+	// project is created.
+	ProxyCache *ProxyCache `yaml:"proxyCache,omitempty"`
+}
+
+type ProxyCache struct {
+	// Registry is the name of a declared registry.
+	Registry string `yaml:"registry"`
+	// Bandwidth limits the speed of pulls from the registry in Kbps. The default, -1,
+	// is unlimited.
+	Bandwidth int `yaml:"bandwidth,omitempty"`
+}
+
+// ProjectMember grants a role to either a user or a group. Members which are not
+// declared are left alone.
+type ProjectMember struct {
+	User  string `yaml:"user,omitempty"`
+	Group string `yaml:"group,omitempty"`
+	Role  string `yaml:"role" jsonschema:"enum=projectAdmin,enum=maintainer,enum=developer,enum=guest"`
+}
+
+// Retention keeps the artifacts matched by any of its rules and deletes all others.
+type Retention struct {
+	// Schedule is a six field cron expression. Without it the policy only runs manually.
+	Schedule string          `yaml:"schedule,omitempty"`
+	Rules    []RetentionRule `yaml:"rules"`
+}
+
+// RetentionRule sets exactly one of the keep conditions. Tags and repositories are
+// doublestar patterns, either included or excluded, and default to all.
+type RetentionRule struct {
+	KeepMostRecent       int    `yaml:"keepMostRecent,omitempty"`
+	KeepRecentlyPulled   int    `yaml:"keepRecentlyPulled,omitempty"`
+	KeepPushedWithinDays int    `yaml:"keepPushedWithinDays,omitempty"`
+	KeepPulledWithinDays int    `yaml:"keepPulledWithinDays,omitempty"`
+	IncludeTags          string `yaml:"includeTags,omitempty"`
+	ExcludeTags          string `yaml:"excludeTags,omitempty"`
+	IncludeRepositories  string `yaml:"includeRepositories,omitempty"`
+	ExcludeRepositories  string `yaml:"excludeRepositories,omitempty"`
+}
+
+// ImmutableRule prevents matching tags from being overwritten or deleted. Tags and
+// repositories are doublestar patterns, either included or excluded, and default to all.
+type ImmutableRule struct {
+	IncludeTags         string `yaml:"includeTags,omitempty"`
+	ExcludeTags         string `yaml:"excludeTags,omitempty"`
+	IncludeRepositories string `yaml:"includeRepositories,omitempty"`
+	ExcludeRepositories string `yaml:"excludeRepositories,omitempty"`
+	Disabled            bool   `yaml:"disabled,omitempty"`
+}
+
+// Webhook notifies a target of project events. Webhooks are identified by their name.
+type Webhook struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Address     string `yaml:"address"`
+	// Type is http, the default, or slack.
+	Type string `yaml:"type,omitempty" jsonschema:"enum=http,enum=slack"`
+	// AuthHeader is sent as the Authorization header of http webhooks.
+	AuthHeader     helpers.Secret `yaml:"authHeader,omitempty"`
+	SkipCertVerify bool           `yaml:"skipCertVerify,omitempty"`
+	// PayloadFormat is Default or CloudEvents, which only http webhooks support.
+	PayloadFormat string   `yaml:"payloadFormat,omitempty" jsonschema:"enum=Default,enum=CloudEvents"`
+	EventTypes    []string `yaml:"eventTypes"`
+	Disabled      bool     `yaml:"disabled,omitempty"`
+}
+
+type CVEAllowlist struct {
+	Items []string `yaml:"items"`
+	// ExpiresAt is a date or an RFC 3339 time. Without it the allowlist never expires.
+	ExpiresAt string `yaml:"expiresAt,omitempty"`
 }
 
 type ReplicationRule struct {
 	Repository           string `yaml:"repository"`
+	Description          string `yaml:"description,omitempty"`
 	SourceRegistry       string `yaml:"sourceRegistry"`
 	DestinationNamespace string `yaml:"destinationNamespace"`
 	Crontab              string `yaml:"crontab"`
 }
 
 type RobotAccount struct {
-	Name    string `yaml:"name"`
-	Token   string `yaml:"token"`
-	Project string `yaml:"project"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// Level is system, the default, or project. A project robot belongs to its only
+	// project and is named robot$<project>+<name> by Harbor. Changing it replaces the
+	// robot and its secret.
+	Level string `yaml:"level,omitempty" jsonschema:"enum=system,enum=project"`
+	Token string `yaml:"token,omitempty"`
+	// Project is a shorthand for a single entry in Projects.
+	Project string `yaml:"project,omitempty"`
+	// Projects the permissions apply to, * for all projects.
+	Projects []string `yaml:"projects,omitempty"`
+	// Permissions default to pulling and reading artifacts, repositories and tags.
+	Permissions []RobotPermission `yaml:"permissions,omitempty"`
+	// Duration is the number of days a secret is valid. By default it never expires.
+	Duration int `yaml:"duration,omitempty"`
+	// RotateBefore is the number of days before the expiry from which runs rotate the
+	// secret, 7 by default.
+	RotateBefore int `yaml:"rotateBefore,omitempty"`
+}
+
+// RobotPermission allows actions such as pull, push, delete or read on a resource such
+// as repository, artifact, tag, scan or helm-chart.
+type RobotPermission struct {
+	Resource string   `yaml:"resource"`
+	Actions  []string `yaml:"actions"`
 }
 
 type Registry struct {
 	Name        string              `yaml:"name"`
-	Description string              `yaml:"description"`
+	Description string              `yaml:"description,omitempty"`
 	Url         string              `yaml:"url"`
 	Type        string              `yaml:"type"`
 	Credentials RegistryCredentials `yaml:"credentials"`
 }
 
 type RegistryCredentials struct {
-	AccessKey    string `yaml:"accessKey"`
-	AccessSecret string `yaml:"accessSecret"`
+	AccessKey    string         `yaml:"accessKey"`
+	AccessSecret helpers.Secret `yaml:"accessSecret,omitempty"`
 }
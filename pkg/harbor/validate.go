@@ -0,0 +1,216 @@
+package harbor
+
+import (
+	"errors"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Harbor schedules replications with a six field cron expression including seconds.
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+func (h *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	projects := map[string]bool{}
+	for i, project := range h.Projects {
+		switch {
+		case project.Name == "":
+			invalid("harbor.projects[%d]: name is required", i)
+		case projects[project.Name]:
+			invalid("harbor.projects[%d]: duplicate project %s", i, project.Name)
+		}
+		projects[project.Name] = true
+
+		if project.StorageLimit != "" {
+			_, err := storageLimit(project)
+			if err != nil {
+				invalid("harbor.projects[%d]: %v", i, err)
+			}
+		}
+		_, err := cveAllowlist(project)
+		if err != nil {
+			invalid("harbor.projects[%d]: %v", i, err)
+		}
+
+		if project.Retention != nil {
+			if len(project.Retention.Rules) == 0 {
+				invalid("harbor.projects[%d].retention: at least one rule is required", i)
+			}
+			if project.Retention.Schedule != "" {
+				_, err := cronParser.Parse(project.Retention.Schedule)
+				if err != nil {
+					invalid("harbor.projects[%d].retention: invalid schedule %q: %v", i, project.Retention.Schedule, err)
+				}
+			}
+			_, err := retentionPolicy(0, *project.Retention)
+			if err != nil {
+				invalid("harbor.projects[%d]: %v", i, err)
+			}
+		}
+
+		rules := map[string]bool{}
+		for j, rule := range project.ImmutableRules {
+			r, err := immutableRule(rule)
+			if err != nil {
+				invalid("harbor.projects[%d].immutableRules[%d]: %v", i, j, err)
+				continue
+			}
+			if rules[immutableRuleKey(r)] {
+				invalid("harbor.projects[%d].immutableRules[%d]: duplicate rule %s", i, j, immutableRuleKey(r))
+			}
+			rules[immutableRuleKey(r)] = true
+		}
+
+		webhooks := map[string]bool{}
+		for j, webhook := range project.Webhooks {
+			switch {
+			case webhook.Name == "":
+				invalid("harbor.projects[%d].webhooks[%d]: name is required", i, j)
+			case webhooks[webhook.Name]:
+				invalid("harbor.projects[%d].webhooks[%d]: duplicate webhook %s", i, j, webhook.Name)
+			}
+			webhooks[webhook.Name] = true
+
+			if webhook.Address == "" {
+				invalid("harbor.projects[%d].webhooks[%d]: address is required", i, j)
+			}
+			if !slices.Contains([]string{"", "http", "slack"}, webhook.Type) {
+				invalid("harbor.projects[%d].webhooks[%d]: type has to be http or slack, found %q", i, j, webhook.Type)
+			}
+			if !slices.Contains([]string{"", "Default", "CloudEvents"}, webhook.PayloadFormat) {
+				invalid("harbor.projects[%d].webhooks[%d]: payloadFormat has to be Default or CloudEvents, found %q", i, j, webhook.PayloadFormat)
+			}
+			if webhook.Type == "slack" && webhook.PayloadFormat == "CloudEvents" {
+				invalid("harbor.projects[%d].webhooks[%d]: slack webhooks only support the Default payload format", i, j)
+			}
+			if len(webhook.EventTypes) == 0 {
+				invalid("harbor.projects[%d].webhooks[%d]: at least one event type is required", i, j)
+			}
+			for _, event := range webhook.EventTypes {
+				if !slices.Contains(webhookEventTypes, event) {
+					invalid("harbor.projects[%d].webhooks[%d]: unknown event type %s, expected one of %s", i, j, event, strings.Join(webhookEventTypes, ", "))
+				}
+			}
+		}
+
+		members := map[string]bool{}
+		for j, member := range project.Members {
+			switch {
+			case (member.User == "") == (member.Group == ""):
+				invalid("harbor.projects[%d].members[%d]: exactly one of user and group is required", i, j)
+			case members[memberKey(member)]:
+				invalid("harbor.projects[%d].members[%d]: duplicate member %s", i, j, memberKey(member))
+			}
+			members[memberKey(member)] = true
+
+			if _, ok := memberRoles[member.Role]; !ok {
+				invalid("harbor.projects[%d].members[%d]: role has to be projectAdmin, maintainer, developer or guest, found %q", i, j, member.Role)
+			}
+		}
+	}
+
+	registries := map[string]bool{}
+	for i, registry := range h.Registries {
+		switch {
+		case registry.Name == "":
+			invalid("harbor.registries[%d]: name is required", i)
+		case registries[registry.Name]:
+			invalid("harbor.registries[%d]: duplicate registry %s", i, registry.Name)
+		}
+		registries[registry.Name] = true
+	}
+
+	for i, project := range h.Projects {
+		if project.ProxyCache == nil {
+			continue
+		}
+		if !registries[project.ProxyCache.Registry] {
+			invalid("harbor.projects[%d].proxyCache: registry %q is not declared in harbor.registries", i, project.ProxyCache.Registry)
+		}
+		if project.ProxyCache.Bandwidth < -1 {
+			invalid("harbor.projects[%d].proxyCache: bandwidth has to be positive or -1, found %d", i, project.ProxyCache.Bandwidth)
+		}
+		if _, ok := project.Metadata["proxy_speed_kb"]; ok {
+			invalid("harbor.projects[%d]: proxy_speed_kb is set through proxyCache.bandwidth", i)
+		}
+	}
+
+	replications := map[string]bool{}
+	for i, rule := range h.Replications {
+		if rule.Repository == "" {
+			invalid("harbor.replications[%d]: repository is required", i)
+		} else if replications[rule.Repository] {
+			invalid("harbor.replications[%d]: duplicate replication of %s", i, rule.Repository)
+		}
+		replications[rule.Repository] = true
+
+		if !registries[rule.SourceRegistry] {
+			invalid("harbor.replications[%d]: sourceRegistry %q is not declared in harbor.registries", i, rule.SourceRegistry)
+		}
+
+		_, err := cronParser.Parse(rule.Crontab)
+		if err != nil {
+			invalid("harbor.replications[%d]: invalid crontab %q: %v", i, rule.Crontab, err)
+		}
+	}
+
+	robots := map[string]bool{}
+	for i, account := range h.RobotAccounts {
+		switch {
+		case account.Name == "":
+			invalid("harbor.robotAccounts[%d]: name is required", i)
+		case robots[account.Name]:
+			invalid("harbor.robotAccounts[%d]: duplicate robot account %s", i, account.Name)
+		}
+		robots[account.Name] = true
+
+		if account.Project != "" && len(account.Projects) > 0 {
+			invalid("harbor.robotAccounts[%d]: only one of project and projects may be set", i)
+		}
+		if len(robotProjects(account)) == 0 {
+			invalid("harbor.robotAccounts[%d]: project or projects is required", i)
+		}
+		for _, project := range robotProjects(account) {
+			if project != "*" && !projects[project] {
+				invalid("harbor.robotAccounts[%d]: project %q is not declared in harbor.projects", i, project)
+			}
+		}
+
+		switch robotLevel(account) {
+		case "system":
+		case "project":
+			if len(robotProjects(account)) != 1 || robotProjects(account)[0] == "*" {
+				invalid("harbor.robotAccounts[%d]: project robots need exactly one project", i)
+			}
+		default:
+			invalid("harbor.robotAccounts[%d]: level has to be system or project, found %q", i, account.Level)
+		}
+
+		if account.Duration < -1 {
+			invalid("harbor.robotAccounts[%d]: duration has to be a number of days or -1, found %d", i, account.Duration)
+		}
+		if account.RotateBefore < 0 {
+			invalid("harbor.robotAccounts[%d]: rotateBefore has to be a number of days, found %d", i, account.RotateBefore)
+		} else if account.Duration > 0 && rotateBefore(account) >= time.Duration(account.Duration)*24*time.Hour {
+			invalid("harbor.robotAccounts[%d]: rotateBefore has to be shorter than the duration of %d days", i, account.Duration)
+		}
+
+		for j, permission := range account.Permissions {
+			if permission.Resource == "" {
+				invalid("harbor.robotAccounts[%d].permissions[%d]: resource is required", i, j)
+			}
+			if len(permission.Actions) == 0 {
+				invalid("harbor.robotAccounts[%d].permissions[%d]: at least one action is required", i, j)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,152 @@
+package harbor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var webhookEventTypes = []string{
+	"DELETE_ARTIFACT",
+	"PULL_ARTIFACT",
+	"PUSH_ARTIFACT",
+	"QUOTA_EXCEED",
+	"QUOTA_WARNING",
+	"REPLICATION",
+	"SCANNING_COMPLETED",
+	"SCANNING_FAILED",
+	"SCANNING_STOPPED",
+	"TAG_RETENTION",
+}
+
+func webhookPolicy(webhook Webhook) api.WebhookPolicy {
+	target := api.WebhookTarget{
+		Type:           webhook.Type,
+		Address:        webhook.Address,
+		AuthHeader:     webhook.AuthHeader.Value,
+		SkipCertVerify: webhook.SkipCertVerify,
+		PayloadFormat:  webhook.PayloadFormat,
+	}
+	if target.Type == "" {
+		target.Type = "http"
+	}
+	if target.PayloadFormat == "" {
+		target.PayloadFormat = "Default"
+	}
+
+	return api.WebhookPolicy{
+		Name:        webhook.Name,
+		Description: webhook.Description,
+		Targets:     []api.WebhookTarget{target},
+		EventTypes:  webhook.EventTypes,
+		Enabled:     !webhook.Disabled,
+	}
+}
+
+// authHeaderChecksum lets plans detect a changed auth header without showing it.
+func authHeaderChecksum(header string) string {
+	if header == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(header))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// webhookValues flattens a policy for plan.NewChange. The auth header is a secret, so
+// only its checksum is compared.
+func webhookValues(policy api.WebhookPolicy) map[string]interface{} {
+	prefix := "webhooks." + policy.Name + "."
+	events := slices.Clone(policy.EventTypes)
+	sort.Strings(events)
+
+	values := map[string]interface{}{
+		prefix + "description": policy.Description,
+		prefix + "event_types": strings.Join(events, ","),
+		prefix + "enabled":     policy.Enabled,
+	}
+	for _, target := range policy.Targets {
+		values[prefix+"type"] = target.Type
+		values[prefix+"address"] = target.Address
+		values[prefix+"auth_header"] = authHeaderChecksum(target.AuthHeader)
+		values[prefix+"skip_cert_verify"] = target.SkipCertVerify
+		values[prefix+"payload_format"] = target.PayloadFormat
+	}
+	return values
+}
+
+func webhookNames(policies []api.WebhookPolicy) string {
+	var names []string
+	for _, policy := range policies {
+		names = append(names, policy.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func webhookPolicies(project Project) []api.WebhookPolicy {
+	var policies []api.WebhookPolicy
+	for _, webhook := range project.Webhooks {
+		policies = append(policies, webhookPolicy(webhook))
+	}
+	return policies
+}
+
+func webhookChanged(live api.WebhookPolicy, desired api.WebhookPolicy) bool {
+	before := webhookValues(live)
+	for key, value := range webhookValues(desired) {
+		if fmt.Sprint(before[key]) != fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncWebhooks creates and updates the declared webhooks and deletes the ones which are
+// not declared. Webhooks of a project which does not exist yet are not looked up.
+func (h *Config) syncWebhooks(project Project, exists bool) error {
+	if project.Webhooks == nil {
+		return nil
+	}
+
+	live := map[string]api.WebhookPolicy{}
+	if exists || !h.DryRun {
+		policies, err := h.client().ListWebhookPolicies(project.Name)
+		if err != nil {
+			return fmt.Errorf("error getting webhooks of project %s: %w", project.Name, err)
+		}
+		for _, policy := range policies {
+			live[policy.Name] = policy
+		}
+	}
+
+	for _, policy := range webhookPolicies(project) {
+		current, ok := live[policy.Name]
+		delete(live, policy.Name)
+		switch {
+		case !ok:
+			_, err := h.client().CreateWebhookPolicy(project.Name, policy)
+			if err != nil {
+				return fmt.Errorf("error creating webhook %s of project %s: %w", policy.Name, project.Name, err)
+			}
+		case webhookChanged(current, policy):
+			policy.ID = current.ID
+			policy.ProjectID = current.ProjectID
+			err := h.client().UpdateWebhookPolicy(project.Name, current.ID, policy)
+			if err != nil {
+				return fmt.Errorf("error updating webhook %s of project %s: %w", policy.Name, project.Name, err)
+			}
+		}
+	}
+
+	for name, policy := range live {
+		err := h.client().DeleteWebhookPolicy(project.Name, policy.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting webhook %s of project %s: %w", name, project.Name, err)
+		}
+	}
+	return nil
+}
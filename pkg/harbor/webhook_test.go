@@ -0,0 +1,60 @@
+package harbor
+
+import (
+	"github.com/thschue/platformer/pkg/harbor/api"
+	"github.com/thschue/platformer/pkg/helpers"
+	"testing"
+)
+
+func TestWebhookChanged(t *testing.T) {
+	webhook := Webhook{
+		Name:       "ci",
+		Address:    "https://ci.example.com/hook",
+		AuthHeader: helpers.Secret{Value: "Bearer one"},
+		EventTypes: []string{"PUSH_ARTIFACT", "DELETE_ARTIFACT"},
+	}
+
+	tests := []struct {
+		name    string
+		live    func(policy *api.WebhookPolicy)
+		changed bool
+	}{
+		{name: "unchanged", live: func(policy *api.WebhookPolicy) {}},
+		{name: "event order", live: func(policy *api.WebhookPolicy) {
+			policy.EventTypes = []string{"DELETE_ARTIFACT", "PUSH_ARTIFACT"}
+		}},
+		{name: "auth header changed", live: func(policy *api.WebhookPolicy) {
+			policy.Targets[0].AuthHeader = "Bearer two"
+		}, changed: true},
+		{name: "auth header removed", live: func(policy *api.WebhookPolicy) {
+			policy.Targets[0].AuthHeader = ""
+		}, changed: true},
+		{name: "address changed", live: func(policy *api.WebhookPolicy) {
+			policy.Targets[0].Address = "https://old.example.com/hook"
+		}, changed: true},
+		{name: "disabled", live: func(policy *api.WebhookPolicy) {
+			policy.Enabled = false
+		}, changed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := webhookPolicy(webhook)
+			live.Targets = append([]api.WebhookTarget(nil), live.Targets...)
+			tt.live(&live)
+
+			if changed := webhookChanged(live, webhookPolicy(webhook)); changed != tt.changed {
+				t.Errorf("expected changed %v, got %v", tt.changed, changed)
+			}
+		})
+	}
+}
+
+func TestWebhookValuesHideAuthHeader(t *testing.T) {
+	policy := webhookPolicy(Webhook{Name: "ci", AuthHeader: helpers.Secret{Value: "Bearer one"}})
+	for key, value := range webhookValues(policy) {
+		if value == "Bearer one" {
+			t.Errorf("%s shows the auth header", key)
+		}
+	}
+}
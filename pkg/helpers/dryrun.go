@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	corev1 "k8s.io/api/core/v1"
+	"log"
+)
+
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"secret":        true,
+	"access_secret": true,
+	"accessSecret":  true,
+	"auth_header":   true,
+	"sshPrivateKey": true,
+	"token":         true,
+}
+
+// LogDryRun logs a write which was skipped because of dry-run mode together with
+// the payload it would have sent. Sensitive values are redacted.
+func LogDryRun(method string, target string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[dry-run] %s %s (payload could not be encoded: %v)\n", method, target, err)
+		return
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err == nil {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(redact(generic)); err == nil {
+			data = bytes.TrimSpace(buf.Bytes())
+		}
+	}
+
+	log.Printf("[dry-run] %s %s %s\n", method, target, data)
+}
+
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if sensitiveKeys[key] && item != "" && item != nil {
+				v[key] = "<redacted>"
+				continue
+			}
+			v[key] = redact(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return value
+}
+
+// SecretPayload converts a Kubernetes secret into a readable payload for LogDryRun.
+func SecretPayload(secret *corev1.Secret) map[string]interface{} {
+	data := map[string]interface{}{}
+	for k, v := range secret.Data {
+		data[k] = string(v)
+	}
+
+	return map[string]interface{}{
+		"name":   secret.Name,
+		"labels": secret.Labels,
+		"data":   data,
+	}
+}
@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"fmt"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
@@ -35,3 +36,16 @@ func BuildKubeConfig() (*rest.Config, error) {
 	}
 	return config, nil
 }
+
+func KubernetesClient() (*kubernetes.Clientset, error) {
+	config, err := BuildKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build kubernetes config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes clientset: %w", err)
+	}
+	return clientset, nil
+}
@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"strings"
+)
+
+const managedMarker = "[managed-by: platformer]"
+
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ComponentLabel = "app.kubernetes.io/component"
+	ManagedBy      = "platformer"
+)
+
+// MarkManaged appends the ownership marker to a description, so that platformer can
+// recognise the resources it created when pruning.
+func MarkManaged(description string) string {
+	if IsManaged(description) {
+		return description
+	}
+	if description == "" {
+		return managedMarker
+	}
+	return description + " " + managedMarker
+}
+
+// UnmarkManaged returns the description without the ownership marker.
+func UnmarkManaged(description string) string {
+	return strings.TrimSpace(strings.Replace(description, managedMarker, "", 1))
+}
+
+func IsManaged(description string) bool {
+	return strings.Contains(description, managedMarker)
+}
+
+func ManagedLabels(component string) map[string]string {
+	return map[string]string{
+		ManagedByLabel: ManagedBy,
+		ComponentLabel: component,
+	}
+}
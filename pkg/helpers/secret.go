@@ -0,0 +1,287 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Secret is a credential which is either written inline or resolved from a reference
+// when the config is loaded, e.g.
+//
+//	password:
+//	  valueFrom:
+//	    kubernetesSecret: {namespace: harbor, name: harbor-admin, key: password}
+type Secret struct {
+	Value     string     `yaml:"value,omitempty"`
+	ValueFrom *SecretRef `yaml:"valueFrom,omitempty"`
+}
+
+type SecretRef struct {
+	File             string               `yaml:"file,omitempty" json:"file,omitempty"`
+	Env              string               `yaml:"env,omitempty" json:"env,omitempty"`
+	KubernetesSecret *KubernetesSecretRef `yaml:"kubernetesSecret,omitempty" json:"kubernetesSecret,omitempty"`
+}
+
+type KubernetesSecretRef struct {
+	Namespace string `yaml:"namespace" json:"namespace"`
+	Name      string `yaml:"name" json:"name"`
+	Key       string `yaml:"key" json:"key"`
+}
+
+// SecretResolver resolves one kind of secret reference.
+type SecretResolver interface {
+	Supports(ref SecretRef) bool
+	Resolve(ctx context.Context, ref SecretRef) (string, error)
+}
+
+var secretResolvers = []SecretResolver{
+	fileSecretResolver{},
+	envSecretResolver{},
+	kubernetesSecretResolver{},
+}
+
+// RegisterSecretResolver adds a resolver for references the built-in resolvers do not
+// support.
+func RegisterSecretResolver(resolver SecretResolver) {
+	secretResolvers = append(secretResolvers, resolver)
+}
+
+// String keeps resolved secrets out of logs.
+func (s Secret) String() string {
+	if s.Value == "" {
+		return ""
+	}
+	return "<redacted>"
+}
+
+// MarshalJSON writes the reference instead of the resolved value, so that checksums of
+// the config do not depend on where it was resolved.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s.ValueFrom != nil {
+		return json.Marshal(map[string]interface{}{"valueFrom": s.ValueFrom})
+	}
+	return json.Marshal(s.Value)
+}
+
+// MarshalYAML writes references as they were declared and inline values as plain
+// strings, the short form accepted when loading the config.
+func (s Secret) MarshalYAML() (interface{}, error) {
+	if s.ValueFrom != nil {
+		return map[string]interface{}{"valueFrom": s.ValueFrom}, nil
+	}
+	return s.Value, nil
+}
+
+// IsSet reports whether the secret has a value or a reference, resolved or not.
+func (s Secret) IsSet() bool {
+	return s.Value != "" || s.ValueFrom != nil
+}
+
+// resolver checks that a reference is well formed and returns the resolver for it,
+// without resolving it.
+func (s *Secret) resolver() (SecretResolver, error) {
+	if s.Value != "" {
+		return nil, errors.New("value and valueFrom are mutually exclusive")
+	}
+
+	var matching []SecretResolver
+	for _, resolver := range secretResolvers {
+		if resolver.Supports(*s.ValueFrom) {
+			matching = append(matching, resolver)
+		}
+	}
+	if len(matching) != 1 {
+		return nil, fmt.Errorf("valueFrom has to reference exactly one source, found %d", len(matching))
+	}
+
+	k := s.ValueFrom.KubernetesSecret
+	if k != nil && (k.Namespace == "" || k.Name == "" || k.Key == "") {
+		return nil, errors.New("kubernetesSecret requires namespace, name and key")
+	}
+	return matching[0], nil
+}
+
+func (s *Secret) resolve(ctx context.Context) error {
+	if s.ValueFrom == nil {
+		return nil
+	}
+
+	resolver, err := s.resolver()
+	if err != nil {
+		return err
+	}
+	value, err := resolver.Resolve(ctx, *s.ValueFrom)
+	if err != nil {
+		return err
+	}
+	s.Value = value
+	return nil
+}
+
+// ResolveSecrets resolves every Secret reachable from v, which has to be a pointer.
+// Errors name the key of the secret which could not be resolved.
+func ResolveSecrets(ctx context.Context, v interface{}) error {
+	var errs []error
+	walkSecrets(reflect.ValueOf(v), "", func(s *Secret) error {
+		return s.resolve(ctx)
+	}, &errs)
+	return errors.Join(errs...)
+}
+
+// CheckSecrets checks that every Secret reachable from v references exactly one
+// source, without reading files, the environment or the cluster.
+func CheckSecrets(v interface{}) error {
+	var errs []error
+	walkSecrets(reflect.ValueOf(v), "", func(s *Secret) error {
+		if s.ValueFrom == nil {
+			return nil
+		}
+		_, err := s.resolver()
+		return err
+	}, &errs)
+	return errors.Join(errs...)
+}
+
+var secretType = reflect.TypeOf(Secret{})
+
+// SecretKeys returns the sorted keys of all Secret fields reachable from the type of v.
+func SecretKeys(v interface{}) []string {
+	found := map[string]bool{}
+	secretKeys(reflect.TypeOf(v), found, map[reflect.Type]bool{})
+
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func secretKeys(t reflect.Type, found map[string]bool, seen map[reflect.Type]bool) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		secretKeys(t.Elem(), found, seen)
+	case reflect.Struct:
+		if seen[t] || t == secretType {
+			return
+		}
+		seen[t] = true
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Pointer || fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType == secretType {
+				found[name] = true
+				continue
+			}
+			secretKeys(field.Type, found, seen)
+		}
+	}
+}
+
+func walkSecrets(v reflect.Value, path string, visit func(s *Secret) error, errs *[]error) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			walkSecrets(v.Elem(), path, visit, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkSecrets(v.Index(i), fmt.Sprintf("%s[%d]", path, i), visit, errs)
+		}
+	case reflect.Struct:
+		if v.Type() == secretType {
+			err := visit(v.Addr().Interface().(*Secret))
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("%s: %w", path, err))
+			}
+			return
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			walkSecrets(v.Field(i), name, visit, errs)
+		}
+	}
+}
+
+type fileSecretResolver struct{}
+
+func (fileSecretResolver) Supports(ref SecretRef) bool {
+	return ref.File != ""
+}
+
+func (fileSecretResolver) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	data, err := os.ReadFile(ref.File)
+	if err != nil {
+		return "", fmt.Errorf("error reading secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+type envSecretResolver struct{}
+
+func (envSecretResolver) Supports(ref SecretRef) bool {
+	return ref.Env != ""
+}
+
+func (envSecretResolver) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	value, ok := os.LookupEnv(ref.Env)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref.Env)
+	}
+	return value, nil
+}
+
+type kubernetesSecretResolver struct{}
+
+func (kubernetesSecretResolver) Supports(ref SecretRef) bool {
+	return ref.KubernetesSecret != nil
+}
+
+func (kubernetesSecretResolver) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	s := ref.KubernetesSecret
+	clientset, err := KubernetesClient()
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := clientset.CoreV1().Secrets(s.Namespace).Get(ctx, s.Name, v1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error getting secret %s/%s: %w", s.Namespace, s.Name, err)
+	}
+
+	value, ok := secret.Data[s.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no key %s", s.Namespace, s.Name, s.Key)
+	}
+	return string(value), nil
+}
@@ -0,0 +1,135 @@
+package helpers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type secretConfig struct {
+	Password Secret   `yaml:"password"`
+	Tokens   []Secret `yaml:"tokens"`
+	Nested   *struct {
+		Key Secret `yaml:"key"`
+	} `yaml:"nested"`
+}
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "password")
+	err := os.WriteFile(file, []byte("from-file\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PLATFORMER_TEST_TOKEN", "from-env")
+
+	tests := []struct {
+		name   string
+		config secretConfig
+		value  string
+		err    string
+	}{
+		{name: "inline value", config: secretConfig{Password: Secret{Value: "inline"}}, value: "inline"},
+		{name: "file", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{File: file}}}, value: "from-file"},
+		{name: "env", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{Env: "PLATFORMER_TEST_TOKEN"}}}, value: "from-env"},
+		{name: "missing file", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{File: filepath.Join(dir, "missing")}}}, err: "password: error reading secret file"},
+		{name: "missing env", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{Env: "PLATFORMER_TEST_MISSING"}}}, err: "password: environment variable PLATFORMER_TEST_MISSING is not set"},
+		{name: "value and reference", config: secretConfig{Password: Secret{Value: "inline", ValueFrom: &SecretRef{Env: "PLATFORMER_TEST_TOKEN"}}}, err: "password: value and valueFrom are mutually exclusive"},
+		{name: "two sources", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{Env: "PLATFORMER_TEST_TOKEN", File: file}}}, err: "password: valueFrom has to reference exactly one source, found 2"},
+		{name: "no source", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{}}}, err: "password: valueFrom has to reference exactly one source, found 0"},
+		{name: "incomplete kubernetes secret", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{KubernetesSecret: &KubernetesSecretRef{Name: "harbor"}}}}, err: "password: kubernetesSecret requires namespace, name and key"},
+		{name: "slice element", config: secretConfig{Tokens: []Secret{{}, {ValueFrom: &SecretRef{Env: "PLATFORMER_TEST_MISSING"}}}}, err: "tokens[1]: environment variable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResolveSecrets(context.Background(), &tt.config)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.config.Password.Value != tt.value {
+				t.Errorf("expected %q, got %q", tt.value, tt.config.Password.Value)
+			}
+		})
+	}
+}
+
+func TestCheckSecrets(t *testing.T) {
+	tests := []struct {
+		name   string
+		config secretConfig
+		err    string
+	}{
+		{name: "unresolvable env", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{Env: "PLATFORMER_TEST_MISSING"}}}},
+		{name: "unreadable file", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{File: "/nonexistent/password"}}}},
+		{name: "kubernetes secret", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{KubernetesSecret: &KubernetesSecretRef{Namespace: "harbor", Name: "admin", Key: "password"}}}}},
+		{name: "two sources", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{Env: "A", File: "b"}}}, err: "password: valueFrom has to reference exactly one source, found 2"},
+		{name: "incomplete kubernetes secret", config: secretConfig{Password: Secret{ValueFrom: &SecretRef{KubernetesSecret: &KubernetesSecretRef{Namespace: "harbor"}}}}, err: "password: kubernetesSecret requires namespace, name and key"},
+		{name: "nested value and reference", config: secretConfig{Nested: &struct {
+			Key Secret `yaml:"key"`
+		}{Key: Secret{Value: "inline", ValueFrom: &SecretRef{Env: "A"}}}}, err: "nested.key: value and valueFrom are mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSecrets(&tt.config)
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+			if tt.config.Password.Value != "" {
+				t.Errorf("expected the secret not to be resolved, got %q", tt.config.Password.Value)
+			}
+		})
+	}
+}
+
+func TestSecretKeys(t *testing.T) {
+	type webhook struct {
+		Name       string `yaml:"name"`
+		AuthHeader Secret `yaml:"authHeader,omitempty"`
+	}
+	type project struct {
+		Webhooks []webhook `yaml:"webhooks"`
+	}
+
+	tests := []struct {
+		name string
+		v    interface{}
+		keys []string
+	}{
+		{name: "fields", v: secretConfig{}, keys: []string{"key", "password", "tokens"}},
+		{name: "nested lists", v: struct {
+			Projects []project `yaml:"projects"`
+		}{}, keys: []string{"authHeader"}},
+		{name: "maps and pointers", v: struct {
+			Registries map[string]*struct {
+				AccessSecret *Secret `yaml:"accessSecret"`
+			} `yaml:"registries"`
+			Credentials Credentials `yaml:"credentials"`
+		}{}, keys: []string{"accessSecret", "password"}},
+		{name: "ignored fields", v: struct {
+			Secret Secret `yaml:"-"`
+			secret Secret
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := SecretKeys(tt.v)
+			if strings.Join(keys, ",") != strings.Join(tt.keys, ",") {
+				t.Errorf("expected %v, got %v", tt.keys, keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+type Backoff struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Timeout         time.Duration
+}
+
+// WaitFor calls probe until it reports the dependency as available. The interval
+// between two probes doubles up to MaxInterval, and waiting stops with an error
+// once Timeout is exceeded.
+func WaitFor(ctx context.Context, name string, backoff Backoff, probe func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, backoff.Timeout)
+	defer cancel()
+
+	interval := backoff.InitialInterval
+	for attempt := 1; ; attempt++ {
+		available, err := probe()
+		if available {
+			if attempt > 1 {
+				log.Printf("%s is available after %d attempts\n", name, attempt)
+			}
+			return nil
+		}
+
+		log.Printf("%s is not available (attempt %d), retrying in %s: %v\n", name, attempt, interval, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not become available within %s: %w", name, backoff.Timeout, err)
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > backoff.MaxInterval {
+			interval = backoff.MaxInterval
+		}
+	}
+}
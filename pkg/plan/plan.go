@@ -0,0 +1,222 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func New(configChecksum string) *Plan {
+	return &Plan{
+		ConfigChecksum: configChecksum,
+		CreatedAt:      time.Now().UTC(),
+	}
+}
+
+// NewChange compares the live state of a resource with its desired state. A nil
+// live state means the resource does not exist yet.
+func NewChange(kind string, name string, live map[string]interface{}, desired map[string]interface{}) Change {
+	change := Change{
+		Kind: kind,
+		Name: name,
+	}
+
+	if live == nil {
+		change.Action = ActionCreate
+		change.Diff = Diff(nil, desired)
+		return change
+	}
+
+	change.Diff = Diff(live, desired)
+	if len(change.Diff) == 0 {
+		change.Action = ActionNoOp
+	} else {
+		change.Action = ActionUpdate
+	}
+	return change
+}
+
+func Diff(live map[string]interface{}, desired map[string]interface{}) []FieldDiff {
+	var diffs []FieldDiff
+
+	keys := make([]string, 0, len(desired))
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		before, exists := live[k]
+		if exists && normalize(before) == normalize(desired[k]) {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{
+			Field:  k,
+			Before: before,
+			After:  desired[k],
+		})
+	}
+	return diffs
+}
+
+func normalize(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		// Numbers decoded from JSON are floats, which fmt prints in exponent notation
+		// once they are large.
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}, []string, []map[string]interface{}:
+		data, err := json.Marshal(value)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+func (p *Plan) Add(changes ...Change) {
+	p.Changes = append(p.Changes, changes...)
+}
+
+func (p *Plan) Count(action Action) int {
+	count := 0
+	for _, change := range p.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func (p *Plan) HasChanges() bool {
+	return len(p.Pending()) > 0
+}
+
+// Pending returns all changes which need to be applied.
+func (p *Plan) Pending() []Change {
+	var pending []Change
+	for _, change := range p.Changes {
+		if change.Action != ActionNoOp {
+			pending = append(pending, change)
+		}
+	}
+	return pending
+}
+
+// Includes reports whether the plan contains a pending change of the given kind
+// for the named resource.
+func (p *Plan) Includes(kind string, name string) bool {
+	for _, change := range p.Pending() {
+		if change.Kind == kind && change.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify makes sure a freshly computed plan still proposes exactly the changes
+// of a previously saved plan, down to the values of every changed field.
+func (p *Plan) Verify(current *Plan) error {
+	if p.ConfigChecksum != current.ConfigChecksum {
+		return fmt.Errorf("configuration changed since the plan was created")
+	}
+
+	saved := map[string]Change{}
+	for _, change := range p.Pending() {
+		saved[change.Kind+" "+change.Name] = change
+	}
+
+	for _, change := range current.Pending() {
+		key := change.Kind + " " + change.Name
+		savedChange, ok := saved[key]
+		if !ok || savedChange.Action != change.Action {
+			return fmt.Errorf("live state changed since the plan was created: %s would now %s", key, change.Action)
+		}
+		if diffString(savedChange.Diff) != diffString(change.Diff) {
+			return fmt.Errorf("live state changed since the plan was created: %s would now change %s", key, diffString(change.Diff))
+		}
+		delete(saved, key)
+	}
+
+	for key := range saved {
+		return fmt.Errorf("live state changed since the plan was created: %s is no longer pending", key)
+	}
+	return nil
+}
+
+// diffString renders a diff the same way for a computed plan and one read from a
+// file, in which all numbers became floats.
+func diffString(diffs []FieldDiff) string {
+	var fields []string
+	for _, diff := range diffs {
+		fields = append(fields, fmt.Sprintf("%s: %s => %s", diff.Field, normalize(diff.Before), normalize(diff.After)))
+	}
+	return strings.Join(fields, ", ")
+}
+
+func (p *Plan) Render(w io.Writer) {
+	for _, change := range p.Changes {
+		switch change.Action {
+		case ActionCreate:
+			fmt.Fprintf(w, "+ %s %s\n", change.Kind, change.Name)
+		case ActionUpdate:
+			fmt.Fprintf(w, "~ %s %s\n", change.Kind, change.Name)
+		case ActionDelete:
+			fmt.Fprintf(w, "- %s %s\n", change.Kind, change.Name)
+		default:
+			fmt.Fprintf(w, "  %s %s (no changes)\n", change.Kind, change.Name)
+			continue
+		}
+
+		for _, diff := range change.Diff {
+			switch change.Action {
+			case ActionCreate:
+				fmt.Fprintf(w, "    %s: %s\n", diff.Field, normalize(diff.After))
+			case ActionDelete:
+				fmt.Fprintf(w, "    %s: %s\n", diff.Field, normalize(diff.Before))
+			default:
+				fmt.Fprintf(w, "    %s: %s => %s\n", diff.Field, normalize(diff.Before), normalize(diff.After))
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete, %d unchanged.\n",
+		p.Count(ActionCreate), p.Count(ActionUpdate), p.Count(ActionDelete), p.Count(ActionNoOp))
+}
+
+func (p *Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(p); err != nil {
+		return fmt.Errorf("error encoding plan: %w", err)
+	}
+	return nil
+}
+
+func (p *Plan) Save(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating plan file: %w", err)
+	}
+	defer file.Close()
+
+	return p.WriteJSON(file)
+}
+
+func Load(filename string) (*Plan, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file: %w", err)
+	}
+
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("error decoding plan file: %w", err)
+	}
+	return &p, nil
+}
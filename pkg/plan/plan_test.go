@@ -0,0 +1,134 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		live    map[string]interface{}
+		desired map[string]interface{}
+		fields  []string
+	}{
+		{name: "equal", live: map[string]interface{}{"public": true}, desired: map[string]interface{}{"public": true}},
+		{name: "changed", live: map[string]interface{}{"public": false}, desired: map[string]interface{}{"public": true}, fields: []string{"public"}},
+		{name: "missing live field", live: map[string]interface{}{}, desired: map[string]interface{}{"public": true}, fields: []string{"public"}},
+		{name: "undeclared live field", live: map[string]interface{}{"public": true, "owner": "admin"}, desired: map[string]interface{}{"public": true}},
+		{name: "json float and int", live: map[string]interface{}{"limit": float64(5497558138880)}, desired: map[string]interface{}{"limit": int64(5497558138880)}},
+		{name: "string and bool", live: map[string]interface{}{"public": "true"}, desired: map[string]interface{}{"public": true}},
+		{name: "slices", live: map[string]interface{}{"events": []string{"a", "b"}}, desired: map[string]interface{}{"events": []string{"b", "a"}}, fields: []string{"events"}},
+		{name: "sorted fields", live: map[string]interface{}{}, desired: map[string]interface{}{"b": 1, "a": 1, "c": 1}, fields: []string{"a", "b", "c"}},
+		{name: "nil live", desired: map[string]interface{}{"public": true}, fields: []string{"public"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, diff := range Diff(tt.live, tt.desired) {
+				fields = append(fields, diff.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("expected diffs in %v, got %v", tt.fields, fields)
+			}
+		})
+	}
+}
+
+func TestNewChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		live   map[string]interface{}
+		action Action
+	}{
+		{name: "missing", action: ActionCreate},
+		{name: "equal", live: map[string]interface{}{"public": true}, action: ActionNoOp},
+		{name: "different", live: map[string]interface{}{"public": false}, action: ActionUpdate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := NewChange("harbor/project", "library", tt.live, map[string]interface{}{"public": true})
+			if change.Action != tt.action {
+				t.Errorf("expected %s, got %s", tt.action, change.Action)
+			}
+		})
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	p := New("checksum")
+	p.Add(
+		Change{Kind: "gitea/repository", Name: "org/app", Action: ActionUpdate},
+		Change{Kind: "gitea/appset", Name: "org/api/dev", Action: ActionCreate},
+		Change{Kind: "harbor/project", Name: "library", Action: ActionNoOp},
+	)
+
+	tests := []struct {
+		kind     string
+		name     string
+		included bool
+	}{
+		{kind: "gitea/repository", name: "org/app", included: true},
+		{kind: "gitea/appset", name: "org/api/dev", included: true},
+		{kind: "harbor/project", name: "library"},
+		{kind: "gitea/repository", name: "org/api"},
+		{kind: "gitea/repository", name: "org/"},
+		{kind: "gitea/", name: "org/app/"},
+		{kind: "gitea/organization", name: "org"},
+		{kind: "harbor/project", name: "org/app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind+" "+tt.name, func(t *testing.T) {
+			if included := p.Includes(tt.kind, tt.name); included != tt.included {
+				t.Errorf("expected %v, got %v", tt.included, included)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	saved := []Change{
+		{Kind: "harbor/project", Name: "library", Action: ActionUpdate, Diff: []FieldDiff{{Field: "storage_limit", Before: float64(10737418240), After: "20Gi"}}},
+		{Kind: "harbor/registry", Name: "hub", Action: ActionNoOp},
+	}
+
+	tests := []struct {
+		name     string
+		checksum string
+		changes  []Change
+		err      string
+	}{
+		{name: "same changes", checksum: "checksum", changes: saved},
+		{name: "no-op changes differ", checksum: "checksum", changes: []Change{saved[0]}},
+		{name: "config changed", checksum: "other", changes: saved, err: "configuration changed"},
+		{name: "action changed", checksum: "checksum", changes: []Change{{Kind: "harbor/project", Name: "library", Action: ActionCreate}}, err: "harbor/project library would now create"},
+		{name: "new change", checksum: "checksum", changes: append([]Change{{Kind: "harbor/registry", Name: "quay", Action: ActionCreate}}, saved...), err: "harbor/registry quay would now create"},
+		{name: "same diff computed", checksum: "checksum", changes: []Change{
+			{Kind: "harbor/project", Name: "library", Action: ActionUpdate, Diff: []FieldDiff{{Field: "storage_limit", Before: int64(10737418240), After: "20Gi"}}},
+		}},
+		{name: "diff changed", checksum: "checksum", changes: []Change{
+			{Kind: "harbor/project", Name: "library", Action: ActionUpdate, Diff: []FieldDiff{{Field: "storage_limit", Before: int64(5368709120), After: "20Gi"}}},
+		}, err: "harbor/project library would now change storage_limit: 5368709120 => 20Gi"},
+		{name: "change gone", checksum: "checksum", changes: []Change{saved[1]}, err: "harbor/project library is no longer pending"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New("checksum")
+			p.Add(saved...)
+			current := New(tt.checksum)
+			current.Add(tt.changes...)
+
+			err := p.Verify(current)
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package plan
+
+import "time"
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionNoOp   Action = "no-op"
+	ActionDelete Action = "delete"
+)
+
+type Plan struct {
+	ConfigChecksum string    `json:"configChecksum"`
+	CreatedAt      time.Time `json:"createdAt"`
+	Changes        []Change  `json:"changes"`
+}
+
+type Change struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	Action Action      `json:"action"`
+	Diff   []FieldDiff `json:"diff,omitempty"`
+}
+
+type FieldDiff struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
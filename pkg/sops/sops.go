@@ -0,0 +1,456 @@
+package sops
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	version = "3.7.3"
+
+	metadataKey = "sops"
+)
+
+var encryptedValue = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+// Metadata is stored next to the encrypted values under the "sops" key, in the same
+// layout as files written by the sops CLI.
+type Metadata struct {
+	Age            []AgeKey `yaml:"age"`
+	LastModified   string   `yaml:"lastmodified"`
+	MAC            string   `yaml:"mac"`
+	EncryptedRegex string   `yaml:"encrypted_regex,omitempty"`
+	Version        string   `yaml:"version"`
+}
+
+type AgeKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+// EncryptedRegex matches exactly the given keys, e.g. the keys of the secret fields of
+// the config file.
+func EncryptedRegex(keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = regexp.QuoteMeta(key)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// IsEncrypted reports whether data is a YAML document with sops metadata.
+func IsEncrypted(data []byte) bool {
+	var doc struct {
+		Sops *Metadata `yaml:"sops"`
+	}
+	err := yaml.Unmarshal(data, &doc)
+	return err == nil && doc.Sops != nil && doc.Sops.MAC != ""
+}
+
+// Encrypt encrypts the values below every key matching encryptedRegex for the given
+// age recipients. All other values stay readable.
+func Encrypt(data []byte, recipients []string, encryptedRegex string) ([]byte, error) {
+	if IsEncrypted(data) {
+		return nil, errors.New("file is already encrypted")
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one age recipient is required")
+	}
+
+	regex, err := regexp.Compile(encryptedRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted regex: %w", err)
+	}
+
+	doc, root, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, 32)
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("error generating data key: %w", err)
+	}
+
+	metadata := Metadata{
+		LastModified:   time.Now().UTC().Format(time.RFC3339),
+		EncryptedRegex: encryptedRegex,
+		Version:        version,
+	}
+	for _, recipient := range recipients {
+		key, err := encryptDataKey(dataKey, recipient)
+		if err != nil {
+			return nil, err
+		}
+		metadata.Age = append(metadata.Age, key)
+	}
+
+	hash := sha512.New()
+	err = walk(root, nil, func(node *yaml.Node, path []string) error {
+		plaintext, valueType, err := scalarValue(node)
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+		}
+		hash.Write(macValue(plaintext, valueType))
+
+		if !matchesPath(regex, path) || plaintext == "" {
+			return nil
+		}
+
+		node.Value, err = encrypt(plaintext, valueType, dataKey, additionalData(path))
+		node.Tag = "!!str"
+		node.Style = 0
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.MAC, err = encrypt(fmt.Sprintf("%X", hash.Sum(nil)), "str", dataKey, metadata.LastModified)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadataNode yaml.Node
+	err = metadataNode.Encode(metadata)
+	if err != nil {
+		return nil, err
+	}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: metadataKey}, &metadataNode)
+
+	return marshal(doc)
+}
+
+// Decrypt decrypts all encrypted values with one of the identities and verifies the MAC
+// of the file. The sops metadata is removed from the result.
+func Decrypt(data []byte, identities []age.Identity) ([]byte, error) {
+	doc, root, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata *Metadata
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != metadataKey {
+			continue
+		}
+		err = root.Content[i+1].Decode(&metadata)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding sops metadata: %w", err)
+		}
+		root.Content = append(root.Content[:i], root.Content[i+2:]...)
+		break
+	}
+	if metadata == nil {
+		return nil, errors.New("file is not encrypted")
+	}
+
+	dataKey, err := decryptDataKey(metadata.Age, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha512.New()
+	err = walk(root, nil, func(node *yaml.Node, path []string) error {
+		if encryptedValue.MatchString(node.Value) {
+			value, valueType, err := decrypt(node.Value, dataKey, additionalData(path))
+			if err != nil {
+				return fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+			}
+			// sops writes booleans capitalized.
+			if valueType == "bool" {
+				value = strings.ToLower(value)
+			}
+			node.Value = value
+			node.Tag = "!!" + valueType
+			node.Style = 0
+		}
+
+		plaintext, valueType, err := scalarValue(node)
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+		}
+		hash.Write(macValue(plaintext, valueType))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mac, _, err := decrypt(metadata.MAC, dataKey, metadata.LastModified)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting MAC: %w", err)
+	}
+	if mac != fmt.Sprintf("%X", hash.Sum(nil)) {
+		return nil, errors.New("MAC mismatch, the file has been modified after it was encrypted")
+	}
+
+	return marshal(doc)
+}
+
+// Identities loads the age identities the same way the sops CLI does: from the
+// SOPS_AGE_KEY and SOPS_AGE_KEY_FILE environment variables, and from
+// sops/age/keys.txt in the user config directory.
+func Identities() ([]age.Identity, error) {
+	var identities []age.Identity
+
+	if key := os.Getenv("SOPS_AGE_KEY"); key != "" {
+		parsed, err := age.ParseIdentities(strings.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing SOPS_AGE_KEY: %w", err)
+		}
+		identities = append(identities, parsed...)
+	}
+
+	var files []string
+	if file := os.Getenv("SOPS_AGE_KEY_FILE"); file != "" {
+		files = append(files, file)
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(dir, "sops", "age", "keys.txt"))
+	}
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error opening age key file: %w", err)
+		}
+		parsed, err := age.ParseIdentities(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing age key file %s: %w", file, err)
+		}
+		identities = append(identities, parsed...)
+	}
+
+	if len(identities) == 0 {
+		return nil, errors.New("no age identity found, set SOPS_AGE_KEY or SOPS_AGE_KEY_FILE")
+	}
+	return identities, nil
+}
+
+func parse(data []byte) (*yaml.Node, *yaml.Node, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing yaml: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, errors.New("expected a yaml document with a mapping at the top")
+	}
+	return &doc, doc.Content[0], nil
+}
+
+func marshal(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err := encoder.Encode(doc)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// walk calls fn for every scalar value with the keys leading to it. Sequence items
+// share the path of their sequence, and the sops metadata is skipped.
+func walk(node *yaml.Node, path []string, fn func(node *yaml.Node, path []string) error) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if len(path) == 0 && key == metadataKey {
+				continue
+			}
+			err := walk(node.Content[i+1], append(path[:len(path):len(path)], key), fn)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			err := walk(item, path, fn)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil
+		}
+		return fn(node, path)
+	}
+	return nil
+}
+
+func matchesPath(regex *regexp.Regexp, path []string) bool {
+	for _, key := range path {
+		if regex.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func additionalData(path []string) string {
+	return strings.Join(path, ":") + ":"
+}
+
+func scalarValue(node *yaml.Node) (string, string, error) {
+	switch node.ShortTag() {
+	case "!!str":
+		return node.Value, "str", nil
+	case "!!int":
+		value, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return "", "", err
+		}
+		return strconv.Itoa(value), "int", nil
+	case "!!float":
+		value, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			return "", "", err
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64), "float", nil
+	case "!!bool":
+		var value bool
+		err := node.Decode(&value)
+		if err != nil {
+			return "", "", err
+		}
+		return strconv.FormatBool(value), "bool", nil
+	}
+	return "", "", fmt.Errorf("unsupported value type %s", node.ShortTag())
+}
+
+// macValue mirrors how sops hashes values, which writes booleans capitalized.
+func macValue(value string, valueType string) []byte {
+	if valueType == "bool" {
+		return []byte(strings.ToUpper(value[:1]) + value[1:])
+	}
+	return []byte(value)
+}
+
+func encrypt(plaintext string, valueType string, key []byte, additionalData string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, 32)
+	_, err = rand.Read(iv)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return "", err
+	}
+
+	out := gcm.Seal(nil, iv, []byte(plaintext), []byte(additionalData))
+	data, tag := out[:len(out)-gcm.Overhead()], out[len(out)-gcm.Overhead():]
+
+	encode := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]", encode(data), encode(iv), encode(tag), valueType), nil
+}
+
+func decrypt(value string, key []byte, additionalData string) (string, string, error) {
+	match := encryptedValue.FindStringSubmatch(value)
+	if match == nil {
+		return "", "", errors.New("value is not encrypted")
+	}
+
+	var parts [3][]byte
+	for i := range parts {
+		decoded, err := base64.StdEncoding.DecodeString(match[i+1])
+		if err != nil {
+			return "", "", fmt.Errorf("error decoding encrypted value: %w", err)
+		}
+		parts[i] = decoded
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return "", "", err
+	}
+
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return "", "", errors.New("could not decrypt value, wrong key or tampered data")
+	}
+	return string(plaintext), match[4], nil
+}
+
+func encryptDataKey(dataKey []byte, recipient string) (AgeKey, error) {
+	parsed, err := age.ParseX25519Recipient(recipient)
+	if err != nil {
+		return AgeKey{}, fmt.Errorf("invalid age recipient %s: %w", recipient, err)
+	}
+
+	var buf bytes.Buffer
+	armored := armor.NewWriter(&buf)
+	w, err := age.Encrypt(armored, parsed)
+	if err != nil {
+		return AgeKey{}, err
+	}
+	_, err = w.Write(dataKey)
+	if err != nil {
+		return AgeKey{}, err
+	}
+	err = w.Close()
+	if err != nil {
+		return AgeKey{}, err
+	}
+	err = armored.Close()
+	if err != nil {
+		return AgeKey{}, err
+	}
+
+	return AgeKey{Recipient: recipient, Enc: buf.String()}, nil
+}
+
+func decryptDataKey(keys []AgeKey, identities []age.Identity) ([]byte, error) {
+	for _, key := range keys {
+		r, err := age.Decrypt(armor.NewReader(strings.NewReader(key.Enc)), identities...)
+		if err != nil {
+			continue
+		}
+		dataKey, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return dataKey, nil
+	}
+	return nil, errors.New("none of the age identities can decrypt the data key")
+}
@@ -0,0 +1,229 @@
+package sops
+
+import (
+	"bytes"
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testRegex = `^(accessSecret|authHeader|password|token)$`
+
+func TestEncryptDecrypt(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		encrypted []string
+		plain     []string
+	}{
+		{
+			name: "credentials",
+			config: `harbor:
+  url: https://harbor.example.com
+  credentials:
+    username: admin
+    password: Harbor12345
+`,
+			encrypted: []string{"Harbor12345"},
+			plain:     []string{"https://harbor.example.com", "admin"},
+		},
+		{
+			name: "secret values and references",
+			config: `gitea:
+  credentials:
+    password:
+      value: inline
+    token:
+      valueFrom:
+        env: GITEA_TOKEN
+`,
+			encrypted: []string{"inline", "GITEA_TOKEN"},
+		},
+		{
+			name: "secrets in lists",
+			config: `harbor:
+  registries:
+    - name: hub
+      credentials:
+        accessKey: robot
+        accessSecret: s3cret
+  projects:
+    - name: library
+      public: true
+      storageLimit: 10
+      webhooks:
+        - name: ci
+          authHeader: Bearer abc
+`,
+			encrypted: []string{"s3cret", "Bearer abc"},
+			plain:     []string{"robot", "library", "ci"},
+		},
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := Encrypt([]byte(tt.config), []string{identity.Recipient().String()}, testRegex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsEncrypted(encrypted) {
+				t.Fatal("expected the file to be encrypted")
+			}
+			for _, value := range tt.encrypted {
+				if strings.Contains(string(encrypted), value) {
+					t.Errorf("expected %q to be encrypted:\n%s", value, encrypted)
+				}
+			}
+			for _, value := range tt.plain {
+				if !strings.Contains(string(encrypted), value) {
+					t.Errorf("expected %q to stay readable:\n%s", value, encrypted)
+				}
+			}
+
+			decrypted, err := Decrypt(encrypted, []age.Identity{identity})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want, got interface{}
+			yaml.Unmarshal([]byte(tt.config), &want)
+			yaml.Unmarshal(decrypted, &got)
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("expected\n%s\ngot\n%s", tt.config, decrypted)
+			}
+		})
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := Encrypt([]byte("harbor:\n  url: https://harbor.example.com\n  password: secret\n"), []string{identity.Recipient().String()}, testRegex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		data       string
+		identities []age.Identity
+		err        string
+	}{
+		{name: "wrong identity", data: string(encrypted), identities: []age.Identity{other}, err: "none of the age identities"},
+		{name: "modified value", data: strings.Replace(string(encrypted), "harbor.example.com", "evil.example.com", 1), identities: []age.Identity{identity}, err: "MAC mismatch"},
+		{name: "not encrypted", data: "harbor:\n  url: https://harbor.example.com\n", identities: []age.Identity{identity}, err: "not encrypted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt([]byte(tt.data), tt.identities)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestEncryptedRegex(t *testing.T) {
+	regex := EncryptedRegex([]string{"authHeader", "password"})
+	if regex != `^(authHeader|password)$` {
+		t.Errorf("unexpected regex %s", regex)
+	}
+}
+
+// testIdentity is the age key testdata/config.sops.yaml was encrypted for with
+// "sops --encrypt --age <recipient> --encrypted-regex <testRegex>" of sops 3.9.0.
+func testIdentity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "age.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identities[0].(*age.X25519Identity)
+}
+
+func assertSameYAML(t *testing.T, want []byte, got []byte) {
+	t.Helper()
+	var wantValue, gotValue interface{}
+	err := yaml.Unmarshal(want, &wantValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = yaml.Unmarshal(got, &gotValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(wantValue, gotValue) {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestDecryptSopsFile(t *testing.T) {
+	plain, err := os.ReadFile(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := os.ReadFile(filepath.Join("testdata", "config.sops.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := Decrypt(encrypted, []age.Identity{testIdentity(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameYAML(t, plain, decrypted)
+}
+
+// TestSopsDecryptsEncryptedFile checks that the sops CLI accepts the files Encrypt
+// writes. It is skipped when sops is not installed.
+func TestSopsDecryptsEncryptedFile(t *testing.T) {
+	sopsPath, err := exec.LookPath("sops")
+	if err != nil {
+		t.Skip("sops is not installed")
+	}
+
+	plain, err := os.ReadFile(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := testIdentity(t)
+	encrypted, err := Encrypt(plain, []string{identity.Recipient().String()}, testRegex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err = os.WriteFile(file, encrypted, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(sopsPath, "--decrypt", "--input-type", "yaml", "--output-type", "yaml", file)
+	cmd.Env = append(os.Environ(), "SOPS_AGE_KEY_FILE="+filepath.Join("testdata", "age.key"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	decrypted, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sops could not decrypt the file: %v\n%s", err, stderr.String())
+	}
+	assertSameYAML(t, plain, decrypted)
+}
@@ -0,0 +1,255 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"github.com/thschue/platformer/pkg/helpers"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sync"
+	"time"
+)
+
+const (
+	kindSecret    = "Secret"
+	kindConfigMap = "ConfigMap"
+
+	stateDataKey  = "state.json"
+	leaseDuration = 2 * time.Minute
+	renewInterval = leaseDuration / 3
+)
+
+// kubernetesBackend keeps the state in a Secret or ConfigMap. The lock is a Lease,
+// which is renewed while held and can be taken over once a crashed run let it expire.
+type kubernetesBackend struct {
+	namespace string
+	name      string
+	kind      string
+	clientset kubernetes.Interface
+	// renewEvery is how often a held lease is renewed.
+	renewEvery time.Duration
+
+	mu          sync.Mutex
+	identity    string
+	stopRenewal context.CancelFunc
+}
+
+func newKubernetesBackend(c KubernetesConfig) (*kubernetesBackend, error) {
+	clientset, err := helpers.KubernetesClient()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &kubernetesBackend{namespace: c.Namespace, name: c.Name, kind: c.Kind, clientset: clientset, renewEvery: renewInterval}
+	if b.name == "" {
+		b.name = "platformer-state"
+	}
+	if b.kind == "" {
+		b.kind = kindSecret
+	}
+	return b, nil
+}
+
+func (b *kubernetesBackend) leaseName() string {
+	return b.name + "-lock"
+}
+
+// Lock takes the lease and renews it in the background. The returned context is
+// cancelled as soon as a renewal fails or another run took the lease over.
+func (b *kubernetesBackend) Lock(ctx context.Context) (context.Context, error) {
+	leases := b.clientset.CoordinationV1().Leases(b.namespace)
+	identity := holder()
+	seconds := int32(leaseDuration.Seconds())
+	now := v1.NewMicroTime(time.Now())
+
+	lease, err := leases.Get(ctx, b.leaseName(), v1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: v1.ObjectMeta{
+				Name:   b.leaseName(),
+				Labels: helpers.ManagedLabels("state"),
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, v1.CreateOptions{})
+	case err == nil:
+		if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "" && !leaseExpired(lease) {
+			return nil, fmt.Errorf("%w by %s since %s", ErrLocked, *lease.Spec.HolderIdentity, lease.Spec.AcquireTime.Format(time.RFC3339))
+		}
+		lease.Spec.HolderIdentity = &identity
+		lease.Spec.LeaseDurationSeconds = &seconds
+		lease.Spec.AcquireTime = &now
+		lease.Spec.RenewTime = &now
+		_, err = leases.Update(ctx, lease, v1.UpdateOptions{})
+	}
+	if errors.IsAlreadyExists(err) || errors.IsConflict(err) {
+		return nil, fmt.Errorf("%w by another run", ErrLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error locking state: %w", err)
+	}
+
+	locked, lost := context.WithCancelCause(ctx)
+	renewCtx, cancel := context.WithCancel(context.Background())
+	b.mu.Lock()
+	b.identity = identity
+	b.stopRenewal = func() {
+		cancel()
+		lost(nil)
+	}
+	b.mu.Unlock()
+	go b.renew(renewCtx, identity, lost)
+	return locked, nil
+}
+
+func leaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return time.Now().After(expiry)
+}
+
+// renew keeps the lease until ctx is done. A renewal which fails, or finds the lease
+// held by another run, reports the lost lock through lost and stops renewing.
+func (b *kubernetesBackend) renew(ctx context.Context, identity string, lost context.CancelCauseFunc) {
+	leases := b.clientset.CoordinationV1().Leases(b.namespace)
+	ticker := time.NewTicker(b.renewEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lease, err := leases.Get(ctx, b.leaseName(), v1.GetOptions{})
+			if err == nil && !heldBy(lease, identity) {
+				err = fmt.Errorf("taken over by %s", leaseHolder(lease))
+			}
+			if err == nil {
+				now := v1.NewMicroTime(time.Now())
+				lease.Spec.RenewTime = &now
+				_, err = leases.Update(ctx, lease, v1.UpdateOptions{})
+			}
+			if err != nil && ctx.Err() == nil {
+				lost(fmt.Errorf("%w: %v", ErrLockLost, err))
+				return
+			}
+		}
+	}
+}
+
+func heldBy(lease *coordinationv1.Lease, identity string) bool {
+	return lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == identity
+}
+
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return "nobody"
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// Unlock deletes the lease only while this run still holds it, so that a run whose
+// lease expired does not release the lock of the run which took it over.
+func (b *kubernetesBackend) Unlock(ctx context.Context) error {
+	b.mu.Lock()
+	if b.stopRenewal != nil {
+		b.stopRenewal()
+		b.stopRenewal = nil
+	}
+	identity := b.identity
+	b.mu.Unlock()
+
+	leases := b.clientset.CoordinationV1().Leases(b.namespace)
+	lease, err := leases.Get(ctx, b.leaseName(), v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error unlocking state: %w", err)
+	}
+	if !heldBy(lease, identity) {
+		return fmt.Errorf("error unlocking state: %w, it is held by %s", ErrLockLost, leaseHolder(lease))
+	}
+
+	err = leases.Delete(ctx, b.leaseName(), v1.DeleteOptions{
+		Preconditions: &v1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if errors.IsConflict(err) {
+		return fmt.Errorf("error unlocking state: %w", ErrLockLost)
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error unlocking state: %w", err)
+	}
+	return nil
+}
+
+func (b *kubernetesBackend) Read(ctx context.Context) (*State, error) {
+	var data []byte
+	if b.kind == kindConfigMap {
+		cm, err := b.clientset.CoreV1().ConfigMaps(b.namespace).Get(ctx, b.name, v1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return New(), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading state: %w", err)
+		}
+		data = []byte(cm.Data[stateDataKey])
+	} else {
+		secret, err := b.clientset.CoreV1().Secrets(b.namespace).Get(ctx, b.name, v1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return New(), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading state: %w", err)
+		}
+		data = secret.Data[stateDataKey]
+	}
+
+	if len(data) == 0 {
+		return New(), nil
+	}
+	return decode(data)
+}
+
+func (b *kubernetesBackend) Write(ctx context.Context, s *State) error {
+	data, err := encode(s)
+	if err != nil {
+		return err
+	}
+
+	meta := v1.ObjectMeta{
+		Name:      b.name,
+		Namespace: b.namespace,
+		Labels:    helpers.ManagedLabels("state"),
+	}
+
+	if b.kind == kindConfigMap {
+		configMaps := b.clientset.CoreV1().ConfigMaps(b.namespace)
+		cm := &corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{stateDataKey: string(data)}}
+		_, err = configMaps.Update(ctx, cm, v1.UpdateOptions{})
+		if errors.IsNotFound(err) {
+			_, err = configMaps.Create(ctx, cm, v1.CreateOptions{})
+		}
+	} else {
+		secrets := b.clientset.CoreV1().Secrets(b.namespace)
+		secret := &corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{stateDataKey: data}}
+		_, err = secrets.Update(ctx, secret, v1.UpdateOptions{})
+		if errors.IsNotFound(err) {
+			_, err = secrets.Create(ctx, secret, v1.CreateOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package state
+
+import (
+	"context"
+	"errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestKubernetesBackendUnlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		takeOver string
+		deleted  bool
+		err      error
+	}{
+		{name: "own lease", deleted: true},
+		{name: "lease taken over", takeOver: "other-run", err: ErrLockLost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &kubernetesBackend{namespace: "platformer", name: "state", clientset: fake.NewSimpleClientset(), renewEvery: renewInterval}
+			ctx := context.Background()
+
+			_, err := b.Lock(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.takeOver != "" {
+				takeOver(t, b, tt.takeOver)
+			}
+
+			err = b.Unlock(ctx)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+
+			_, err = b.clientset.CoordinationV1().Leases(b.namespace).Get(ctx, b.leaseName(), v1.GetOptions{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.deleted {
+				t.Errorf("expected lease deleted %t, got %t (%v)", tt.deleted, deleted, err)
+			}
+		})
+	}
+}
+
+func TestKubernetesBackendLockLost(t *testing.T) {
+	b := &kubernetesBackend{namespace: "platformer", name: "state", clientset: fake.NewSimpleClientset(), renewEvery: 10 * time.Millisecond}
+	locked, err := b.Lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	takeOver(t, b, "other-run")
+
+	select {
+	case <-locked.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the lock context to be cancelled")
+	}
+	if !errors.Is(context.Cause(locked), ErrLockLost) {
+		t.Errorf("expected %v, got %v", ErrLockLost, context.Cause(locked))
+	}
+
+	err = b.Unlock(context.Background())
+	if !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected %v, got %v", ErrLockLost, err)
+	}
+}
+
+// takeOver hands the lease to another holder, as a run would after it expired.
+func takeOver(t *testing.T, b *kubernetesBackend, identity string) {
+	t.Helper()
+	leases := b.clientset.CoordinationV1().Leases(b.namespace)
+	lease, err := leases.Get(context.Background(), b.leaseName(), v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease.Spec = coordinationv1.LeaseSpec{HolderIdentity: &identity}
+	_, err = leases.Update(context.Background(), lease, v1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,122 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// localBackend keeps the state in a JSON file next to a lock file, which is created
+// exclusively while a run holds the lock.
+type localBackend struct {
+	path string
+}
+
+type lockInfo struct {
+	Holder string    `json:"holder"`
+	Since  time.Time `json:"since"`
+}
+
+func (b *localBackend) lockPath() string {
+	return b.path + ".lock"
+}
+
+func (b *localBackend) Lock(ctx context.Context) (context.Context, error) {
+	f, err := os.OpenFile(b.lockPath(), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if errors.Is(err, os.ErrExist) {
+		var info lockInfo
+		data, _ := os.ReadFile(b.lockPath())
+		json.Unmarshal(data, &info)
+		return nil, fmt.Errorf("%w by %s since %s, remove %s if that run is no longer active", ErrLocked, info.Holder, info.Since.Format(time.RFC3339), b.lockPath())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error locking state: %w", err)
+	}
+	defer f.Close()
+
+	err = json.NewEncoder(f).Encode(lockInfo{Holder: holder(), Since: time.Now().UTC()})
+	if err != nil {
+		return nil, fmt.Errorf("error locking state: %w", err)
+	}
+	return ctx, nil
+}
+
+func (b *localBackend) Unlock(ctx context.Context) error {
+	err := os.Remove(b.lockPath())
+	if err != nil {
+		return fmt.Errorf("error unlocking state: %w", err)
+	}
+	return nil
+}
+
+func (b *localBackend) Read(ctx context.Context) (*State, error) {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state: %w", err)
+	}
+	return decode(data)
+}
+
+// Write replaces the file atomically, so that an interrupted run never leaves a
+// truncated state behind.
+func (b *localBackend) Write(ctx context.Context, s *State) error {
+	data, err := encode(s)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), filepath.Base(b.path)+".*")
+	if err != nil {
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing state: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), b.path)
+	if err != nil {
+		return fmt.Errorf("error writing state: %w", err)
+	}
+	return nil
+}
+
+func encode(s *State) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Serial++
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding state: %w", err)
+	}
+	return data, nil
+}
+
+func decode(data []byte) (*State, error) {
+	s := New()
+	err := json.Unmarshal(data, s)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding state: %w", err)
+	}
+	if s.Version > formatVersion {
+		return nil, fmt.Errorf("state version %d is newer than the supported version %d", s.Version, formatVersion)
+	}
+	if s.Resources == nil {
+		s.Resources = map[string]Resource{}
+	}
+	return s, nil
+}
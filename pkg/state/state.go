@@ -0,0 +1,159 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	BackendLocal      = "local"
+	BackendKubernetes = "kubernetes"
+
+	formatVersion = 1
+)
+
+// State records the objects platformer created in Harbor and Gitea, keyed by the kind
+// and name of the resource which owns them. A nil State records nothing, so callers
+// do not have to check whether state is enabled.
+type State struct {
+	Version   int                 `json:"version"`
+	Serial    int64               `json:"serial"`
+	Resources map[string]Resource `json:"resources"`
+
+	mu sync.Mutex
+}
+
+type Resource struct {
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	ID         int64             `json:"id,omitempty"`
+	SHA        string            `json:"sha,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+}
+
+// Backend stores the state. Lock has to be held while reading and writing the state
+// during a run, so that concurrent runs do not overwrite each other. The context
+// returned by Lock is cancelled when the lock is lost before Unlock.
+type Backend interface {
+	Lock(ctx context.Context) (context.Context, error)
+	Unlock(ctx context.Context) error
+	Read(ctx context.Context) (*State, error)
+	Write(ctx context.Context, s *State) error
+}
+
+type Config struct {
+	Backend    string           `yaml:"backend" jsonschema:"enum=local,enum=kubernetes"`
+	Path       string           `yaml:"path"`
+	Kubernetes KubernetesConfig `yaml:"kubernetes"`
+}
+
+type KubernetesConfig struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	Kind      string `yaml:"kind" jsonschema:"enum=Secret,enum=ConfigMap"`
+}
+
+var (
+	ErrLocked   = errors.New("state is locked")
+	ErrLockLost = errors.New("state lock was lost")
+)
+
+func New() *State {
+	return &State{Version: formatVersion, Resources: map[string]Resource{}}
+}
+
+func key(kind string, name string) string {
+	return kind + "/" + name
+}
+
+func (s *State) Get(kind string, name string) (Resource, bool) {
+	if s == nil {
+		return Resource{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.Resources[key(kind, name)]
+	return r, ok
+}
+
+func (s *State) Set(r Resource) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.UpdatedAt = time.Now().UTC()
+	s.Resources[key(r.Kind, r.Name)] = r
+}
+
+func (s *State) Delete(kind string, name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Resources, key(kind, name))
+}
+
+// List returns all resources ordered by kind and name.
+func (s *State) List() []Resource {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Resource, 0, len(s.Resources))
+	for _, r := range s.Resources {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return key(list[i].Kind, list[i].Name) < key(list[j].Kind, list[j].Name)
+	})
+	return list
+}
+
+// NewBackend returns the backend configured in the config file. Without a backend the
+// state is kept in a local file.
+func NewBackend(c Config) (Backend, error) {
+	switch c.Backend {
+	case "", BackendLocal:
+		path := c.Path
+		if path == "" {
+			path = ".platformer.state.json"
+		}
+		return &localBackend{path: path}, nil
+	case BackendKubernetes:
+		return newKubernetesBackend(c.Kubernetes)
+	}
+	return nil, fmt.Errorf("unknown state backend %s", c.Backend)
+}
+
+// Validate checks the backend settings without accessing the backend.
+func (c Config) Validate() error {
+	switch c.Backend {
+	case "", BackendLocal:
+		return nil
+	case BackendKubernetes:
+		if c.Kubernetes.Namespace == "" {
+			return errors.New("state.kubernetes.namespace is required")
+		}
+		if c.Kubernetes.Kind != "" && c.Kubernetes.Kind != kindSecret && c.Kubernetes.Kind != kindConfigMap {
+			return fmt.Errorf("state.kubernetes.kind has to be %s or %s", kindSecret, kindConfigMap)
+		}
+		return nil
+	}
+	return fmt.Errorf("state.backend has to be %s or %s", BackendLocal, BackendKubernetes)
+}
+
+// holder identifies this process in locks.
+func holder() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d", hostname, os.Getpid())
+}
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalBackendLock(t *testing.T) {
+	tests := []struct {
+		name   string
+		locks  int
+		unlock bool
+		err    error
+	}{
+		{name: "first lock", locks: 1},
+		{name: "held lock", locks: 2, err: ErrLocked},
+		{name: "released lock", locks: 2, unlock: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &localBackend{path: filepath.Join(t.TempDir(), "state.json")}
+			ctx := context.Background()
+
+			var err error
+			for i := 0; i < tt.locks; i++ {
+				_, err = b.Lock(ctx)
+				if i == 0 && err != nil {
+					t.Fatal(err)
+				}
+				if i == 0 && tt.unlock {
+					err = b.Unlock(ctx)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestLocalBackendReadWrite(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []Resource
+		deleted   []string
+	}{
+		{name: "empty"},
+		{name: "resources", resources: []Resource{
+			{Kind: "harbor/registry", Name: "hub", ID: 3},
+			{Kind: "gitea/deploy-key", Name: "org/repo", ID: 7, Attributes: map[string]string{"title": "argocd"}},
+		}},
+		{name: "deleted resource", resources: []Resource{
+			{Kind: "harbor/registry", Name: "hub", ID: 3},
+			{Kind: "harbor/registry", Name: "quay", ID: 4},
+		}, deleted: []string{"quay"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &localBackend{path: filepath.Join(t.TempDir(), "state.json")}
+			ctx := context.Background()
+
+			s, err := b.Read(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range tt.resources {
+				s.Set(r)
+			}
+			for _, name := range tt.deleted {
+				s.Delete("harbor/registry", name)
+			}
+			err = b.Write(ctx, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			read, err := b.Read(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if read.Serial != 1 {
+				t.Errorf("expected serial 1, got %d", read.Serial)
+			}
+			if len(read.List()) != len(tt.resources)-len(tt.deleted) {
+				t.Errorf("expected %d resources, got %+v", len(tt.resources)-len(tt.deleted), read.List())
+			}
+			for _, r := range tt.resources[:len(tt.resources)-len(tt.deleted)] {
+				got, ok := read.Get(r.Kind, r.Name)
+				if !ok || got.ID != r.ID || len(got.Attributes) != len(r.Attributes) {
+					t.Errorf("expected %+v, got %+v", r, got)
+				}
+			}
+			for _, name := range tt.deleted {
+				if _, ok := read.Get("harbor/registry", name); ok {
+					t.Errorf("expected %s to be deleted", name)
+				}
+			}
+		})
+	}
+}
+
+func TestNilState(t *testing.T) {
+	var s *State
+	s.Set(Resource{Kind: "harbor/registry", Name: "hub", ID: 3})
+	s.Delete("harbor/registry", "hub")
+	if _, ok := s.Get("harbor/registry", "hub"); ok {
+		t.Error("expected a nil state to record nothing")
+	}
+	if len(s.List()) != 0 {
+		t.Error("expected a nil state to list nothing")
+	}
+}